@@ -0,0 +1,307 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/maintenance"
+	"github.com/ONSdigital/dis-redirect-proxy/redirects"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+//go:generate moq -out mock/cache.go -pkg mock . CachePurger
+//go:generate moq -out mock/maintenance.go -pkg mock . MaintenanceSwitch
+//go:generate moq -out mock/redirects.go -pkg mock . RedirectStore
+//go:generate moq -out mock/reloader.go -pkg mock . Reloader
+
+// PathPrefix is the path under which the admin endpoints are served
+const PathPrefix = "/admin"
+
+// CachePurger defines the methods required to purge cached responses
+type CachePurger interface {
+	Purge(ctx context.Context, pathPrefix string) (int, error)
+}
+
+// MaintenanceSwitch defines the methods required to put parts of the site into and out of maintenance
+type MaintenanceSwitch interface {
+	Windows() []maintenance.Window
+	Enable(ctx context.Context, window maintenance.Window) error
+	Disable(ctx context.Context, pathPrefix string) (bool, error)
+}
+
+// RedirectStore defines the methods required to set and delete redirects, and to view their history
+type RedirectStore interface {
+	Set(ctx context.Context, path string, entry redirects.Entry, now time.Time) (redirects.Entry, error)
+	Delete(ctx context.Context, path string, metadata redirects.Metadata, now time.Time) (bool, error)
+	History(ctx context.Context, path string) ([]redirects.Change, error)
+}
+
+// Reloader defines the method required to reload the config of the running service
+type Reloader interface {
+	Reload(ctx context.Context) error
+}
+
+// API provides the administrative endpoints of the proxy
+type API struct {
+	Router      *mux.Router
+	cache       CachePurger
+	maintenance MaintenanceSwitch
+	redirects   RedirectStore
+	reloader    Reloader
+}
+
+// errorResponse is the body of an unsuccessful admin request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// purgeResponse is the body of a successful cache purge
+type purgeResponse struct {
+	PathPrefix string `json:"path_prefix"`
+	Purged     int    `json:"purged"`
+}
+
+// maintenanceResponse is the body of a successful maintenance request
+type maintenanceResponse struct {
+	Windows []maintenance.Window `json:"windows"`
+}
+
+// deleteRedirectResponse is the body of a successful redirect deletion
+type deleteRedirectResponse struct {
+	Path    string `json:"path"`
+	Deleted bool   `json:"deleted"`
+}
+
+// redirectHistoryResponse is the body of a successful request for the history of a redirect
+type redirectHistoryResponse struct {
+	Path    string             `json:"path"`
+	History []redirects.Change `json:"history"`
+}
+
+// reloadResponse is the body of a successful config reload
+type reloadResponse struct {
+	Status string `json:"status"`
+}
+
+// Setup registers the admin endpoints on r, which should be a subrouter for PathPrefix created before the
+// proxy's catch-all route. Every endpoint requires the configured admin API key as a bearer token, and the
+// admin API is disabled if no key is configured. cache, maintenanceSwitch and redirectStore may be nil if
+// response caching, maintenance mode or redirects are disabled, and reloader may be nil if the config can't be
+// reloaded.
+func Setup(ctx context.Context, r *mux.Router, cfg *config.Config, cache CachePurger, maintenanceSwitch MaintenanceSwitch, redirectStore RedirectStore, reloader Reloader) *API {
+	api := &API{
+		Router:      r,
+		cache:       cache,
+		maintenance: maintenanceSwitch,
+		redirects:   redirectStore,
+		reloader:    reloader,
+	}
+
+	if cfg.AdminAPIKey == "" {
+		log.Info(ctx, "admin API key not configured, admin API disabled")
+		return api
+	}
+
+	r.Use(authorise(cfg.AdminAPIKey))
+
+	if cache != nil {
+		r.Path("/cache").Methods(http.MethodDelete).HandlerFunc(api.purgeCache)
+	}
+	if maintenanceSwitch != nil {
+		r.Path("/maintenance").Methods(http.MethodGet).HandlerFunc(api.getMaintenance)
+		r.Path("/maintenance").Methods(http.MethodPut).HandlerFunc(api.enableMaintenance)
+		r.Path("/maintenance").Methods(http.MethodDelete).HandlerFunc(api.disableMaintenance)
+	}
+	if redirectStore != nil {
+		r.Path("/redirects").Methods(http.MethodPut).HandlerFunc(api.setRedirect)
+		r.Path("/redirects").Methods(http.MethodDelete).HandlerFunc(api.deleteRedirect)
+		r.Path("/redirects/history").Methods(http.MethodGet).HandlerFunc(api.getRedirectHistory)
+	}
+	if reloader != nil {
+		r.Path("/reload").Methods(http.MethodPost).HandlerFunc(api.reload)
+	}
+
+	return api
+}
+
+// authorise rejects requests that do not carry the admin API key as a bearer token
+func authorise(apiKey string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(apiKey)) != 1 {
+				log.Warn(req.Context(), "unauthorised admin API request", log.Data{"path": req.URL.Path})
+				writeJSON(req.Context(), w, http.StatusUnauthorized, errorResponse{Error: "unauthorised"})
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// purgeCache handles DELETE /admin/cache?path_prefix=/some/path
+func (api *API) purgeCache(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	pathPrefix := req.URL.Query().Get("path_prefix")
+	if !strings.HasPrefix(pathPrefix, "/") {
+		writeJSON(ctx, w, http.StatusBadRequest, errorResponse{Error: "path_prefix must be provided and start with /"})
+		return
+	}
+
+	purged, err := api.cache.Purge(ctx, pathPrefix)
+	if err != nil {
+		log.Error(ctx, "failed to purge cache", err, log.Data{"path_prefix": pathPrefix})
+		writeJSON(ctx, w, http.StatusInternalServerError, errorResponse{Error: "failed to purge cache"})
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, purgeResponse{PathPrefix: pathPrefix, Purged: purged})
+}
+
+// getMaintenance handles GET /admin/maintenance
+func (api *API) getMaintenance(w http.ResponseWriter, req *http.Request) {
+	writeJSON(req.Context(), w, http.StatusOK, maintenanceResponse{Windows: api.maintenance.Windows()})
+}
+
+// enableMaintenance handles PUT /admin/maintenance with a maintenance window as the body
+func (api *API) enableMaintenance(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var window maintenance.Window
+	if err := json.NewDecoder(req.Body).Decode(&window); err != nil {
+		writeJSON(ctx, w, http.StatusBadRequest, errorResponse{Error: "invalid maintenance window: " + err.Error()})
+		return
+	}
+
+	if err := api.maintenance.Enable(ctx, window); err != nil {
+		if errors.Is(err, maintenance.ErrInvalidPathPrefix) {
+			writeJSON(ctx, w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		log.Error(ctx, "failed to enable maintenance", err, log.Data{"path_prefix": window.PathPrefix})
+		writeJSON(ctx, w, http.StatusInternalServerError, errorResponse{Error: "failed to enable maintenance"})
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, maintenanceResponse{Windows: api.maintenance.Windows()})
+}
+
+// disableMaintenance handles DELETE /admin/maintenance?path_prefix=/some/path
+func (api *API) disableMaintenance(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	pathPrefix := req.URL.Query().Get("path_prefix")
+
+	disabled, err := api.maintenance.Disable(ctx, pathPrefix)
+	if err != nil {
+		log.Error(ctx, "failed to disable maintenance", err, log.Data{"path_prefix": pathPrefix})
+		writeJSON(ctx, w, http.StatusInternalServerError, errorResponse{Error: "failed to disable maintenance"})
+		return
+	}
+	if !disabled {
+		writeJSON(ctx, w, http.StatusNotFound, errorResponse{Error: "path prefix is not in maintenance"})
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, maintenanceResponse{Windows: api.maintenance.Windows()})
+}
+
+// setRedirect handles PUT /admin/redirects with a redirect as the body, which must name its author
+func (api *API) setRedirect(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var redirect redirects.Redirect
+	if err := json.NewDecoder(req.Body).Decode(&redirect); err != nil {
+		writeJSON(ctx, w, http.StatusBadRequest, errorResponse{Error: "invalid redirect: " + err.Error()})
+		return
+	}
+	if redirect.Author == "" {
+		writeJSON(ctx, w, http.StatusBadRequest, errorResponse{Error: "author must be provided"})
+		return
+	}
+
+	entry, err := api.redirects.Set(ctx, redirect.Path, redirect.Entry, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, redirects.ErrInvalidPath) || errors.Is(err, redirects.ErrNoTarget) || errors.Is(err, redirects.ErrInvalidRedirect) {
+			writeJSON(ctx, w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		log.Error(ctx, "failed to set redirect", err, log.Data{"path": redirect.Path})
+		writeJSON(ctx, w, http.StatusInternalServerError, errorResponse{Error: "failed to set redirect"})
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, redirects.Redirect{Path: redirect.Path, Entry: entry})
+}
+
+// deleteRedirect handles DELETE /admin/redirects?path=/some/path&author=name, optionally with a ticket and
+// source
+func (api *API) deleteRedirect(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	query := req.URL.Query()
+	path := query.Get("path")
+	metadata := redirects.Metadata{Author: query.Get("author"), Ticket: query.Get("ticket"), Source: query.Get("source")}
+	if metadata.Author == "" {
+		writeJSON(ctx, w, http.StatusBadRequest, errorResponse{Error: "author must be provided"})
+		return
+	}
+
+	deleted, err := api.redirects.Delete(ctx, path, metadata, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, redirects.ErrInvalidPath) {
+			writeJSON(ctx, w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		log.Error(ctx, "failed to delete redirect", err, log.Data{"path": path})
+		writeJSON(ctx, w, http.StatusInternalServerError, errorResponse{Error: "failed to delete redirect"})
+		return
+	}
+	if !deleted {
+		writeJSON(ctx, w, http.StatusNotFound, errorResponse{Error: "redirect not found"})
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, deleteRedirectResponse{Path: path, Deleted: true})
+}
+
+// getRedirectHistory handles GET /admin/redirects/history?path=/some/path
+func (api *API) getRedirectHistory(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	path := req.URL.Query().Get("path")
+
+	history, err := api.redirects.History(ctx, path)
+	if err != nil {
+		log.Error(ctx, "failed to get redirect history", err, log.Data{"path": path})
+		writeJSON(ctx, w, http.StatusInternalServerError, errorResponse{Error: "failed to get redirect history"})
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, redirectHistoryResponse{Path: path, History: history})
+}
+
+// reload handles POST /admin/reload
+func (api *API) reload(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	if err := api.reloader.Reload(ctx); err != nil {
+		log.Error(ctx, "failed to reload config", err)
+		writeJSON(ctx, w, http.StatusInternalServerError, errorResponse{Error: "failed to reload config: " + err.Error()})
+		return
+	}
+
+	writeJSON(ctx, w, http.StatusOK, reloadResponse{Status: "reloaded"})
+}
+
+func writeJSON(ctx context.Context, w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error(ctx, "error writing admin API response", err)
+	}
+}
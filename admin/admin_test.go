@@ -0,0 +1,365 @@
+package admin_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/admin"
+	"github.com/ONSdigital/dis-redirect-proxy/admin/mock"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/maintenance"
+	"github.com/ONSdigital/dis-redirect-proxy/redirects"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testAPIKey = "test-admin-key"
+
+func TestPurgeCache(t *testing.T) {
+	Convey("Given an admin API with a cache", t, func() {
+		ctx := context.Background()
+		cache := &mock.CachePurgerMock{
+			PurgeFunc: func(ctx context.Context, pathPrefix string) (int, error) {
+				return 3, nil
+			},
+		}
+		cfg := &config.Config{AdminAPIKey: testAPIKey}
+		r := mux.NewRouter()
+		admin.Setup(ctx, r.PathPrefix(admin.PathPrefix).Subrouter(), cfg, cache, nil, nil, nil)
+
+		purge := func(query, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodDelete, "/admin/cache"+query, http.NoBody)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When a purge is requested with the API key", func() {
+			w := purge("?path_prefix=/economy", testAPIKey)
+
+			Convey("Then the cache is purged for the path prefix", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, `{"path_prefix":"/economy","purged":3}`+"\n")
+				So(cache.PurgeCalls(), ShouldHaveLength, 1)
+				So(cache.PurgeCalls()[0].PathPrefix, ShouldEqual, "/economy")
+			})
+		})
+
+		Convey("When a purge is requested without the API key", func() {
+			w := purge("?path_prefix=/economy", "")
+
+			Convey("Then it is rejected", func() {
+				So(w.Code, ShouldEqual, http.StatusUnauthorized)
+				So(cache.PurgeCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a purge is requested with the wrong API key", func() {
+			w := purge("?path_prefix=/economy", "wrong")
+
+			Convey("Then it is rejected", func() {
+				So(w.Code, ShouldEqual, http.StatusUnauthorized)
+				So(cache.PurgeCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a purge is requested without a path prefix", func() {
+			w := purge("", testAPIKey)
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(cache.PurgeCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the cache fails to purge", func() {
+			cache.PurgeFunc = func(ctx context.Context, pathPrefix string) (int, error) {
+				return 0, errors.New("redis unavailable")
+			}
+			w := purge("?path_prefix=/economy", testAPIKey)
+
+			Convey("Then an internal server error is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+
+	Convey("Given an admin API without an API key configured", t, func() {
+		cache := &mock.CachePurgerMock{}
+		r := mux.NewRouter()
+		admin.Setup(context.Background(), r.PathPrefix(admin.PathPrefix).Subrouter(), &config.Config{}, cache, nil, nil, nil)
+
+		Convey("When a purge is requested", func() {
+			req := httptest.NewRequest(http.MethodDelete, "/admin/cache?path_prefix=/economy", http.NoBody)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			Convey("Then the endpoint is not registered", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				So(cache.PurgeCalls(), ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestMaintenance(t *testing.T) {
+	Convey("Given an admin API with maintenance mode", t, func() {
+		ctx := context.Background()
+		windows := []maintenance.Window{{PathPrefix: "/economy"}}
+		maintenanceSwitch := &mock.MaintenanceSwitchMock{
+			WindowsFunc: func() []maintenance.Window {
+				return windows
+			},
+			EnableFunc: func(ctx context.Context, window maintenance.Window) error {
+				if !strings.HasPrefix(window.PathPrefix, "/") {
+					return maintenance.ErrInvalidPathPrefix
+				}
+				windows = append(windows, window)
+				return nil
+			},
+			DisableFunc: func(ctx context.Context, pathPrefix string) (bool, error) {
+				return pathPrefix == "/economy", nil
+			},
+		}
+		cfg := &config.Config{AdminAPIKey: testAPIKey}
+		r := mux.NewRouter()
+		admin.Setup(ctx, r.PathPrefix(admin.PathPrefix).Subrouter(), cfg, nil, maintenanceSwitch, nil, nil)
+
+		serve := func(method, target, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, target, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+testAPIKey)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When the maintenance windows are requested", func() {
+			w := serve(http.MethodGet, "/admin/maintenance", "")
+
+			Convey("Then they are returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, `{"windows":[{"path_prefix":"/economy"}]}`+"\n")
+			})
+		})
+
+		Convey("When maintenance is enabled for a path prefix", func() {
+			w := serve(http.MethodPut, "/admin/maintenance", `{"path_prefix":"/releases","retry_after":"10m"}`)
+
+			Convey("Then the window is enabled", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(maintenanceSwitch.EnableCalls(), ShouldHaveLength, 1)
+				window := maintenanceSwitch.EnableCalls()[0].Window
+				So(window.PathPrefix, ShouldEqual, "/releases")
+				So(window.RetryAfter, ShouldEqual, config.Duration(10*time.Minute))
+			})
+		})
+
+		Convey("When maintenance is enabled with an invalid path prefix", func() {
+			w := serve(http.MethodPut, "/admin/maintenance", `{"path_prefix":"releases"}`)
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When maintenance is enabled with an invalid body", func() {
+			w := serve(http.MethodPut, "/admin/maintenance", `{"path_prefix":`)
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(maintenanceSwitch.EnableCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When maintenance is disabled for a path prefix", func() {
+			w := serve(http.MethodDelete, "/admin/maintenance?path_prefix=/economy", "")
+
+			Convey("Then the window is disabled", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(maintenanceSwitch.DisableCalls()[0].PathPrefix, ShouldEqual, "/economy")
+			})
+		})
+
+		Convey("When maintenance is disabled for a path prefix that isn't in maintenance", func() {
+			w := serve(http.MethodDelete, "/admin/maintenance?path_prefix=/people", "")
+
+			Convey("Then not found is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
+
+func TestRedirects(t *testing.T) {
+	Convey("Given an admin API that manages redirects", t, func() {
+		ctx := context.Background()
+		created := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+		redirectStore := &mock.RedirectStoreMock{
+			SetFunc: func(ctx context.Context, path string, entry redirects.Entry, now time.Time) (redirects.Entry, error) {
+				if !strings.HasPrefix(path, "/") {
+					return redirects.Entry{}, redirects.ErrInvalidPath
+				}
+				entry.CreatedAt = &created
+				return entry, nil
+			},
+			DeleteFunc: func(ctx context.Context, path string, metadata redirects.Metadata, now time.Time) (bool, error) {
+				if !strings.HasPrefix(path, "/") {
+					return false, redirects.ErrInvalidPath
+				}
+				return path == "/old", nil
+			},
+			HistoryFunc: func(ctx context.Context, path string) ([]redirects.Change, error) {
+				if path == "/broken" {
+					return nil, errors.New("invalid redirect history")
+				}
+				return []redirects.Change{{Action: redirects.ActionSet, At: created, Metadata: redirects.Metadata{Author: "alice"}}}, nil
+			},
+		}
+		cfg := &config.Config{AdminAPIKey: testAPIKey}
+		r := mux.NewRouter()
+		admin.Setup(ctx, r.PathPrefix(admin.PathPrefix).Subrouter(), cfg, nil, nil, redirectStore, nil)
+
+		serve := func(method, target, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, target, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+testAPIKey)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When a redirect is set with its metadata", func() {
+			w := serve(http.MethodPut, "/admin/redirects",
+				`{"path":"/old","to":"/new","not_after":"2026-04-01T00:00:00Z","author":"alice","ticket":"DIS-123","source":"admin"}`)
+
+			Convey("Then it is stored and returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, `{"path":"/old","to":"/new","not_after":"2026-04-01T00:00:00Z",`+
+					`"author":"alice","ticket":"DIS-123","source":"admin","created_at":"2026-03-01T09:00:00Z"}`+"\n")
+				call := redirectStore.SetCalls()[0]
+				So(call.Path, ShouldEqual, "/old")
+				So(call.Entry.To, ShouldEqual, "/new")
+				So(call.Entry.Metadata, ShouldResemble, redirects.Metadata{Author: "alice", Ticket: "DIS-123", Source: "admin"})
+			})
+		})
+
+		Convey("When a redirect is set without an author", func() {
+			w := serve(http.MethodPut, "/admin/redirects", `{"path":"/old","to":"/new"}`)
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(redirectStore.SetCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When an invalid redirect is set", func() {
+			w := serve(http.MethodPut, "/admin/redirects", `{"path":"old","to":"/new","author":"alice"}`)
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldEqual, `{"error":"path must start with / and must not contain { or }"}`+"\n")
+			})
+		})
+
+		Convey("When a redirect is deleted", func() {
+			w := serve(http.MethodDelete, "/admin/redirects?path=/old&author=bob&ticket=DIS-456", "")
+
+			Convey("Then it is deleted with the metadata of the change", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, `{"path":"/old","deleted":true}`+"\n")
+				So(redirectStore.DeleteCalls()[0].Metadata, ShouldResemble, redirects.Metadata{Author: "bob", Ticket: "DIS-456"})
+			})
+		})
+
+		Convey("When a redirect that doesn't exist is deleted", func() {
+			w := serve(http.MethodDelete, "/admin/redirects?path=/missing&author=bob", "")
+
+			Convey("Then not found is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When a redirect with an invalid path is deleted", func() {
+			w := serve(http.MethodDelete, "/admin/redirects?path=old&author=bob", "")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldEqual, `{"error":"path must start with / and must not contain { or }"}`+"\n")
+			})
+		})
+
+		Convey("When a redirect is deleted without an author", func() {
+			w := serve(http.MethodDelete, "/admin/redirects?path=/old", "")
+
+			Convey("Then a bad request is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(redirectStore.DeleteCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the history of a redirect is requested", func() {
+			w := serve(http.MethodGet, "/admin/redirects/history?path=/old", "")
+
+			Convey("Then it is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual,
+					`{"path":"/old","history":[{"action":"set","at":"2026-03-01T09:00:00Z","author":"alice"}]}`+"\n")
+			})
+		})
+
+		Convey("When the history of a redirect can't be read", func() {
+			w := serve(http.MethodGet, "/admin/redirects/history?path=/broken", "")
+
+			Convey("Then an internal server error is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
+
+func TestReload(t *testing.T) {
+	Convey("Given an admin API that can reload the config", t, func() {
+		ctx := context.Background()
+		reloader := &mock.ReloaderMock{
+			ReloadFunc: func(ctx context.Context) error { return nil },
+		}
+		cfg := &config.Config{AdminAPIKey: testAPIKey}
+		r := mux.NewRouter()
+		admin.Setup(ctx, r.PathPrefix(admin.PathPrefix).Subrouter(), cfg, nil, nil, nil, reloader)
+
+		reload := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/admin/reload", http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+testAPIKey)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When a reload is requested", func() {
+			w := reload()
+
+			Convey("Then the config is reloaded", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, `{"status":"reloaded"}`+"\n")
+				So(reloader.ReloadCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the reload fails", func() {
+			reloader.ReloadFunc = func(ctx context.Context) error { return errors.New("invalid config") }
+			w := reload()
+
+			Convey("Then an internal server error is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(w.Body.String(), ShouldContainSubstring, "invalid config")
+			})
+		})
+	})
+}
@@ -0,0 +1,82 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/ONSdigital/dis-redirect-proxy/admin"
+	"sync"
+)
+
+// Ensure, that CachePurgerMock does implement admin.CachePurger.
+// If this is not the case, regenerate this file with moq.
+var _ admin.CachePurger = &CachePurgerMock{}
+
+// CachePurgerMock is a mock implementation of admin.CachePurger.
+//
+//	func TestSomethingThatUsesCachePurger(t *testing.T) {
+//
+//		// make and configure a mocked admin.CachePurger
+//		mockedCachePurger := &CachePurgerMock{
+//			PurgeFunc: func(ctx context.Context, pathPrefix string) (int, error) {
+//				panic("mock out the Purge method")
+//			},
+//		}
+//
+//		// use mockedCachePurger in code that requires admin.CachePurger
+//		// and then make assertions.
+//
+//	}
+type CachePurgerMock struct {
+	// PurgeFunc mocks the Purge method.
+	PurgeFunc func(ctx context.Context, pathPrefix string) (int, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Purge holds details about calls to the Purge method.
+		Purge []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// PathPrefix is the pathPrefix argument value.
+			PathPrefix string
+		}
+	}
+	lockPurge sync.RWMutex
+}
+
+// Purge calls PurgeFunc.
+func (mock *CachePurgerMock) Purge(ctx context.Context, pathPrefix string) (int, error) {
+	if mock.PurgeFunc == nil {
+		panic("CachePurgerMock.PurgeFunc: method is nil but CachePurger.Purge was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		PathPrefix string
+	}{
+		Ctx:        ctx,
+		PathPrefix: pathPrefix,
+	}
+	mock.lockPurge.Lock()
+	mock.calls.Purge = append(mock.calls.Purge, callInfo)
+	mock.lockPurge.Unlock()
+	return mock.PurgeFunc(ctx, pathPrefix)
+}
+
+// PurgeCalls gets all the calls that were made to Purge.
+// Check the length with:
+//
+//	len(mockedCachePurger.PurgeCalls())
+func (mock *CachePurgerMock) PurgeCalls() []struct {
+	Ctx        context.Context
+	PathPrefix string
+} {
+	var calls []struct {
+		Ctx        context.Context
+		PathPrefix string
+	}
+	mock.lockPurge.RLock()
+	calls = mock.calls.Purge
+	mock.lockPurge.RUnlock()
+	return calls
+}
@@ -0,0 +1,170 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/ONSdigital/dis-redirect-proxy/admin"
+	"github.com/ONSdigital/dis-redirect-proxy/maintenance"
+	"sync"
+)
+
+// Ensure, that MaintenanceSwitchMock does implement admin.MaintenanceSwitch.
+// If this is not the case, regenerate this file with moq.
+var _ admin.MaintenanceSwitch = &MaintenanceSwitchMock{}
+
+// MaintenanceSwitchMock is a mock implementation of admin.MaintenanceSwitch.
+//
+//	func TestSomethingThatUsesMaintenanceSwitch(t *testing.T) {
+//
+//		// make and configure a mocked admin.MaintenanceSwitch
+//		mockedMaintenanceSwitch := &MaintenanceSwitchMock{
+//			DisableFunc: func(ctx context.Context, pathPrefix string) (bool, error) {
+//				panic("mock out the Disable method")
+//			},
+//			EnableFunc: func(ctx context.Context, window maintenance.Window) error {
+//				panic("mock out the Enable method")
+//			},
+//			WindowsFunc: func() []maintenance.Window {
+//				panic("mock out the Windows method")
+//			},
+//		}
+//
+//		// use mockedMaintenanceSwitch in code that requires admin.MaintenanceSwitch
+//		// and then make assertions.
+//
+//	}
+type MaintenanceSwitchMock struct {
+	// DisableFunc mocks the Disable method.
+	DisableFunc func(ctx context.Context, pathPrefix string) (bool, error)
+
+	// EnableFunc mocks the Enable method.
+	EnableFunc func(ctx context.Context, window maintenance.Window) error
+
+	// WindowsFunc mocks the Windows method.
+	WindowsFunc func() []maintenance.Window
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Disable holds details about calls to the Disable method.
+		Disable []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// PathPrefix is the pathPrefix argument value.
+			PathPrefix string
+		}
+		// Enable holds details about calls to the Enable method.
+		Enable []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Window is the window argument value.
+			Window maintenance.Window
+		}
+		// Windows holds details about calls to the Windows method.
+		Windows []struct {
+		}
+	}
+	lockDisable sync.RWMutex
+	lockEnable  sync.RWMutex
+	lockWindows sync.RWMutex
+}
+
+// Disable calls DisableFunc.
+func (mock *MaintenanceSwitchMock) Disable(ctx context.Context, pathPrefix string) (bool, error) {
+	if mock.DisableFunc == nil {
+		panic("MaintenanceSwitchMock.DisableFunc: method is nil but MaintenanceSwitch.Disable was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		PathPrefix string
+	}{
+		Ctx:        ctx,
+		PathPrefix: pathPrefix,
+	}
+	mock.lockDisable.Lock()
+	mock.calls.Disable = append(mock.calls.Disable, callInfo)
+	mock.lockDisable.Unlock()
+	return mock.DisableFunc(ctx, pathPrefix)
+}
+
+// DisableCalls gets all the calls that were made to Disable.
+// Check the length with:
+//
+//	len(mockedMaintenanceSwitch.DisableCalls())
+func (mock *MaintenanceSwitchMock) DisableCalls() []struct {
+	Ctx        context.Context
+	PathPrefix string
+} {
+	var calls []struct {
+		Ctx        context.Context
+		PathPrefix string
+	}
+	mock.lockDisable.RLock()
+	calls = mock.calls.Disable
+	mock.lockDisable.RUnlock()
+	return calls
+}
+
+// Enable calls EnableFunc.
+func (mock *MaintenanceSwitchMock) Enable(ctx context.Context, window maintenance.Window) error {
+	if mock.EnableFunc == nil {
+		panic("MaintenanceSwitchMock.EnableFunc: method is nil but MaintenanceSwitch.Enable was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Window maintenance.Window
+	}{
+		Ctx:    ctx,
+		Window: window,
+	}
+	mock.lockEnable.Lock()
+	mock.calls.Enable = append(mock.calls.Enable, callInfo)
+	mock.lockEnable.Unlock()
+	return mock.EnableFunc(ctx, window)
+}
+
+// EnableCalls gets all the calls that were made to Enable.
+// Check the length with:
+//
+//	len(mockedMaintenanceSwitch.EnableCalls())
+func (mock *MaintenanceSwitchMock) EnableCalls() []struct {
+	Ctx    context.Context
+	Window maintenance.Window
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Window maintenance.Window
+	}
+	mock.lockEnable.RLock()
+	calls = mock.calls.Enable
+	mock.lockEnable.RUnlock()
+	return calls
+}
+
+// Windows calls WindowsFunc.
+func (mock *MaintenanceSwitchMock) Windows() []maintenance.Window {
+	if mock.WindowsFunc == nil {
+		panic("MaintenanceSwitchMock.WindowsFunc: method is nil but MaintenanceSwitch.Windows was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockWindows.Lock()
+	mock.calls.Windows = append(mock.calls.Windows, callInfo)
+	mock.lockWindows.Unlock()
+	return mock.WindowsFunc()
+}
+
+// WindowsCalls gets all the calls that were made to Windows.
+// Check the length with:
+//
+//	len(mockedMaintenanceSwitch.WindowsCalls())
+func (mock *MaintenanceSwitchMock) WindowsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockWindows.RLock()
+	calls = mock.calls.Windows
+	mock.lockWindows.RUnlock()
+	return calls
+}
@@ -0,0 +1,208 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/ONSdigital/dis-redirect-proxy/admin"
+	"github.com/ONSdigital/dis-redirect-proxy/redirects"
+	"sync"
+	"time"
+)
+
+// Ensure, that RedirectStoreMock does implement admin.RedirectStore.
+// If this is not the case, regenerate this file with moq.
+var _ admin.RedirectStore = &RedirectStoreMock{}
+
+// RedirectStoreMock is a mock implementation of admin.RedirectStore.
+//
+//	func TestSomethingThatUsesRedirectStore(t *testing.T) {
+//
+//		// make and configure a mocked admin.RedirectStore
+//		mockedRedirectStore := &RedirectStoreMock{
+//			DeleteFunc: func(ctx context.Context, path string, metadata redirects.Metadata, now time.Time) (bool, error) {
+//				panic("mock out the Delete method")
+//			},
+//			HistoryFunc: func(ctx context.Context, path string) ([]redirects.Change, error) {
+//				panic("mock out the History method")
+//			},
+//			SetFunc: func(ctx context.Context, path string, entry redirects.Entry, now time.Time) (redirects.Entry, error) {
+//				panic("mock out the Set method")
+//			},
+//		}
+//
+//		// use mockedRedirectStore in code that requires admin.RedirectStore
+//		// and then make assertions.
+//
+//	}
+type RedirectStoreMock struct {
+	// DeleteFunc mocks the Delete method.
+	DeleteFunc func(ctx context.Context, path string, metadata redirects.Metadata, now time.Time) (bool, error)
+
+	// HistoryFunc mocks the History method.
+	HistoryFunc func(ctx context.Context, path string) ([]redirects.Change, error)
+
+	// SetFunc mocks the Set method.
+	SetFunc func(ctx context.Context, path string, entry redirects.Entry, now time.Time) (redirects.Entry, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Delete holds details about calls to the Delete method.
+		Delete []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Path is the path argument value.
+			Path string
+			// Metadata is the metadata argument value.
+			Metadata redirects.Metadata
+			// Now is the now argument value.
+			Now time.Time
+		}
+		// History holds details about calls to the History method.
+		History []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Path is the path argument value.
+			Path string
+		}
+		// Set holds details about calls to the Set method.
+		Set []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Path is the path argument value.
+			Path string
+			// Entry is the entry argument value.
+			Entry redirects.Entry
+			// Now is the now argument value.
+			Now time.Time
+		}
+	}
+	lockDelete  sync.RWMutex
+	lockHistory sync.RWMutex
+	lockSet     sync.RWMutex
+}
+
+// Delete calls DeleteFunc.
+func (mock *RedirectStoreMock) Delete(ctx context.Context, path string, metadata redirects.Metadata, now time.Time) (bool, error) {
+	if mock.DeleteFunc == nil {
+		panic("RedirectStoreMock.DeleteFunc: method is nil but RedirectStore.Delete was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Path     string
+		Metadata redirects.Metadata
+		Now      time.Time
+	}{
+		Ctx:      ctx,
+		Path:     path,
+		Metadata: metadata,
+		Now:      now,
+	}
+	mock.lockDelete.Lock()
+	mock.calls.Delete = append(mock.calls.Delete, callInfo)
+	mock.lockDelete.Unlock()
+	return mock.DeleteFunc(ctx, path, metadata, now)
+}
+
+// DeleteCalls gets all the calls that were made to Delete.
+// Check the length with:
+//
+//	len(mockedRedirectStore.DeleteCalls())
+func (mock *RedirectStoreMock) DeleteCalls() []struct {
+	Ctx      context.Context
+	Path     string
+	Metadata redirects.Metadata
+	Now      time.Time
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Path     string
+		Metadata redirects.Metadata
+		Now      time.Time
+	}
+	mock.lockDelete.RLock()
+	calls = mock.calls.Delete
+	mock.lockDelete.RUnlock()
+	return calls
+}
+
+// History calls HistoryFunc.
+func (mock *RedirectStoreMock) History(ctx context.Context, path string) ([]redirects.Change, error) {
+	if mock.HistoryFunc == nil {
+		panic("RedirectStoreMock.HistoryFunc: method is nil but RedirectStore.History was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		Path string
+	}{
+		Ctx:  ctx,
+		Path: path,
+	}
+	mock.lockHistory.Lock()
+	mock.calls.History = append(mock.calls.History, callInfo)
+	mock.lockHistory.Unlock()
+	return mock.HistoryFunc(ctx, path)
+}
+
+// HistoryCalls gets all the calls that were made to History.
+// Check the length with:
+//
+//	len(mockedRedirectStore.HistoryCalls())
+func (mock *RedirectStoreMock) HistoryCalls() []struct {
+	Ctx  context.Context
+	Path string
+} {
+	var calls []struct {
+		Ctx  context.Context
+		Path string
+	}
+	mock.lockHistory.RLock()
+	calls = mock.calls.History
+	mock.lockHistory.RUnlock()
+	return calls
+}
+
+// Set calls SetFunc.
+func (mock *RedirectStoreMock) Set(ctx context.Context, path string, entry redirects.Entry, now time.Time) (redirects.Entry, error) {
+	if mock.SetFunc == nil {
+		panic("RedirectStoreMock.SetFunc: method is nil but RedirectStore.Set was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Path  string
+		Entry redirects.Entry
+		Now   time.Time
+	}{
+		Ctx:   ctx,
+		Path:  path,
+		Entry: entry,
+		Now:   now,
+	}
+	mock.lockSet.Lock()
+	mock.calls.Set = append(mock.calls.Set, callInfo)
+	mock.lockSet.Unlock()
+	return mock.SetFunc(ctx, path, entry, now)
+}
+
+// SetCalls gets all the calls that were made to Set.
+// Check the length with:
+//
+//	len(mockedRedirectStore.SetCalls())
+func (mock *RedirectStoreMock) SetCalls() []struct {
+	Ctx   context.Context
+	Path  string
+	Entry redirects.Entry
+	Now   time.Time
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Path  string
+		Entry redirects.Entry
+		Now   time.Time
+	}
+	mock.lockSet.RLock()
+	calls = mock.calls.Set
+	mock.lockSet.RUnlock()
+	return calls
+}
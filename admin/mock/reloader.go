@@ -0,0 +1,76 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/ONSdigital/dis-redirect-proxy/admin"
+	"sync"
+)
+
+// Ensure, that ReloaderMock does implement admin.Reloader.
+// If this is not the case, regenerate this file with moq.
+var _ admin.Reloader = &ReloaderMock{}
+
+// ReloaderMock is a mock implementation of admin.Reloader.
+//
+//	func TestSomethingThatUsesReloader(t *testing.T) {
+//
+//		// make and configure a mocked admin.Reloader
+//		mockedReloader := &ReloaderMock{
+//			ReloadFunc: func(ctx context.Context) error {
+//				panic("mock out the Reload method")
+//			},
+//		}
+//
+//		// use mockedReloader in code that requires admin.Reloader
+//		// and then make assertions.
+//
+//	}
+type ReloaderMock struct {
+	// ReloadFunc mocks the Reload method.
+	ReloadFunc func(ctx context.Context) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Reload holds details about calls to the Reload method.
+		Reload []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+	}
+	lockReload sync.RWMutex
+}
+
+// Reload calls ReloadFunc.
+func (mock *ReloaderMock) Reload(ctx context.Context) error {
+	if mock.ReloadFunc == nil {
+		panic("ReloaderMock.ReloadFunc: method is nil but Reloader.Reload was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockReload.Lock()
+	mock.calls.Reload = append(mock.calls.Reload, callInfo)
+	mock.lockReload.Unlock()
+	return mock.ReloadFunc(ctx)
+}
+
+// ReloadCalls gets all the calls that were made to Reload.
+// Check the length with:
+//
+//	len(mockedReloader.ReloadCalls())
+func (mock *ReloaderMock) ReloadCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockReload.RLock()
+	calls = mock.calls.Reload
+	mock.lockReload.RUnlock()
+	return calls
+}
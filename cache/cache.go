@@ -0,0 +1,325 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/metrics"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+const (
+	// KeyPrefix is prepended to every cache key, so cached responses can share a Redis database with redirects
+	KeyPrefix = "cache:"
+
+	// variantMarker separates the request part of a key from the values of the headers named in Vary
+	variantMarker = "#v:"
+
+	// CacheStatusHeader tells the client whether the response came from the cache
+	CacheStatusHeader = "X-Cache"
+
+	cacheStatusHit   = "HIT"
+	cacheStatusMiss  = "MISS"
+	cacheStatusStale = "STALE"
+)
+
+// headers that describe the connection or a single response and so are never stored
+var unstoredHeaders = []string{"Age", "Connection", "Keep-Alive", "Transfer-Encoding", "X-Request-Id", CacheStatusHeader}
+
+// entry is a stored response
+type entry struct {
+	StatusCode           int           `json:"status_code"`
+	Header               http.Header   `json:"header"`
+	Body                 []byte        `json:"body"`
+	StoredAt             time.Time     `json:"stored_at"`
+	Fresh                time.Duration `json:"fresh"`
+	StaleWhileRevalidate time.Duration `json:"stale_while_revalidate"`
+	StaleIfError         time.Duration `json:"stale_if_error"`
+}
+
+// Cache is an HTTP response cache that sits in front of an upstream handler
+type Cache struct {
+	store        Store
+	rules        config.CacheRules
+	authCookies  []string
+	maxBodySize  int64
+	now          func() time.Time
+	revalidating sync.Map
+}
+
+// New creates a Cache from the service configuration, storing responses in Redis if configured to
+func New(cfg *config.Config, redisCli clients.Redis) (*Cache, error) {
+	var store Store
+	switch cfg.CacheStore {
+	case config.CacheStoreMemory, "":
+		store = NewMemoryStore(cfg.CacheMaxEntries, cfg.CacheMaxBytes)
+	case config.CacheStoreRedis:
+		if redisCli == nil {
+			return nil, errors.New("redis cache store requires a redis client")
+		}
+		store = NewRedisStore(redisCli)
+	default:
+		return nil, fmt.Errorf("unknown cache store: %q", cfg.CacheStore)
+	}
+
+	return NewWithStore(store, cfg.CacheRules, cfg.CacheAuthCookies, cfg.CacheMaxBodySize), nil
+}
+
+// NewWithStore creates a Cache using the given store. Requests with any of authCookies bypass the cache.
+func NewWithStore(store Store, rules config.CacheRules, authCookies []string, maxBodySize int64) *Cache {
+	return &Cache{
+		store:       store,
+		rules:       rules,
+		authCookies: authCookies,
+		maxBodySize: maxBodySize,
+		now:         time.Now,
+	}
+}
+
+// Handler returns a handler that serves GET requests from the cache where possible, and otherwise calls
+// next and stores its response if it is cacheable
+func (c *Cache) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rule := matchRule(c.rules, req.URL.Path)
+		if req.Method != http.MethodGet || (rule != nil && rule.Disabled) || c.authenticated(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		ctx := req.Context()
+		key := requestKey(req)
+
+		var (
+			cached *entry
+			age    time.Duration
+		)
+		if _, noCache := parseCacheControl(req.Header)["no-cache"]; !noCache {
+			cached = c.lookup(ctx, key, req)
+		}
+		if cached != nil {
+			age = c.now().Sub(cached.StoredAt)
+			switch {
+			case age < cached.Fresh:
+				metrics.RecordCacheLookup(ctx, cacheStatusHit)
+				serveEntry(ctx, w, cached, age, cacheStatusHit)
+				return
+			case age < cached.Fresh+cached.StaleWhileRevalidate:
+				metrics.RecordCacheLookup(ctx, cacheStatusStale)
+				serveEntry(ctx, w, cached, age, cacheStatusStale)
+				c.revalidate(req, key, rule, next)
+				return
+			}
+		}
+
+		// keep a stale entry that can stand in for an upstream error
+		var fallback *entry
+		if cached != nil && age < cached.Fresh+cached.StaleIfError {
+			fallback = cached
+		}
+
+		metrics.RecordCacheLookup(ctx, cacheStatusMiss)
+		w.Header().Set(CacheStatusHeader, cacheStatusMiss)
+		cw := NewCaptureWriter(w, c.maxBodySize, func(statusCode int) bool {
+			return fallback != nil && statusCode >= http.StatusInternalServerError
+		})
+		next.ServeHTTP(cw, req)
+
+		if cw.suppressed {
+			log.Warn(ctx, "upstream error, serving stale response from cache", log.Data{
+				"path": req.URL.Path, "status_code": cw.statusCode, "age": age.String(),
+			})
+			w.Header().Del(CacheStatusHeader)
+			serveEntry(ctx, w, fallback, age, cacheStatusStale)
+			return
+		}
+		c.save(ctx, key, req, rule, cw)
+	})
+}
+
+// Purge removes every cached response whose request path starts with pathPrefix, returning the number of
+// responses removed
+func (c *Cache) Purge(ctx context.Context, pathPrefix string) (int, error) {
+	keys, err := c.store.Keys(ctx, KeyPrefix+pathPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list cache keys: %w", err)
+	}
+
+	purged := 0
+	for _, key := range keys {
+		if err := c.store.Delete(ctx, key); err != nil {
+			return purged, fmt.Errorf("failed to delete cache key %q: %w", key, err)
+		}
+		if strings.Contains(key, variantMarker) {
+			purged++
+		}
+	}
+
+	log.Info(ctx, "purged cached responses", log.Data{"path_prefix": pathPrefix, "purged": purged})
+	return purged, nil
+}
+
+// lookup returns the stored entry for the request, or nil if there isn't one. The key holds the names of the
+// headers the response varies on, which are used to find the variant that matches the request.
+func (c *Cache) lookup(ctx context.Context, key string, req *http.Request) *entry {
+	varyValue, err := c.store.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Error(ctx, "error reading from cache", err, log.Data{"key": key})
+		}
+		return nil
+	}
+
+	var vary []string
+	if err := json.Unmarshal(varyValue, &vary); err != nil {
+		log.Error(ctx, "error decoding cached vary headers", err, log.Data{"key": key})
+		return nil
+	}
+
+	value, err := c.store.Get(ctx, variantKey(key, vary, req.Header))
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Error(ctx, "error reading from cache", err, log.Data{"key": key})
+		}
+		return nil
+	}
+
+	var e entry
+	if err := json.Unmarshal(value, &e); err != nil {
+		log.Error(ctx, "error decoding cached response", err, log.Data{"key": key})
+		return nil
+	}
+	return &e
+}
+
+// save stores a captured response if it is cacheable
+func (c *Cache) save(ctx context.Context, key string, req *http.Request, rule *config.CacheRule, cw *CaptureWriter) {
+	body, complete := cw.Body()
+	if !complete {
+		return
+	}
+	f, ok := responseFreshness(req, cw.statusCode, cw.header, rule, c.now())
+	if !ok {
+		return
+	}
+
+	header := cw.header.Clone()
+	for _, name := range unstoredHeaders {
+		header.Del(name)
+	}
+	e := entry{
+		StatusCode:           cw.statusCode,
+		Header:               header,
+		Body:                 body,
+		StoredAt:             c.now(),
+		Fresh:                f.fresh,
+		StaleWhileRevalidate: f.staleWhileRevalidate,
+		StaleIfError:         f.staleIfError,
+	}
+
+	vary := varyHeaders(header)
+	varyValue, err := json.Marshal(vary)
+	if err != nil {
+		log.Error(ctx, "error encoding vary headers for cache", err)
+		return
+	}
+	value, err := json.Marshal(e)
+	if err != nil {
+		log.Error(ctx, "error encoding response for cache", err)
+		return
+	}
+
+	ttl := f.storageTTL()
+	if err := c.store.Set(ctx, key, varyValue, ttl); err != nil {
+		log.Error(ctx, "error writing to cache", err, log.Data{"key": key})
+		return
+	}
+	if err := c.store.Set(ctx, variantKey(key, vary, req.Header), value, ttl); err != nil {
+		log.Error(ctx, "error writing to cache", err, log.Data{"key": key})
+	}
+}
+
+// revalidate refreshes a stale entry in the background, making sure only one refresh per key is in flight
+func (c *Cache) revalidate(req *http.Request, key string, rule *config.CacheRule, next http.Handler) {
+	if _, inFlight := c.revalidating.LoadOrStore(key, struct{}{}); inFlight {
+		return
+	}
+
+	ctx := context.WithoutCancel(req.Context())
+	bgReq := req.Clone(ctx)
+	go func() {
+		defer c.revalidating.Delete(key)
+		cw := NewCaptureWriter(&DiscardWriter{}, c.maxBodySize, nil)
+		next.ServeHTTP(cw, bgReq)
+		c.save(ctx, key, bgReq, rule, cw)
+	}()
+}
+
+// authenticated reports whether a request could get a response specific to the user, which is never served
+// from or stored in the cache, even if the upstream doesn't mark it private
+func (c *Cache) authenticated(req *http.Request) bool {
+	if req.Header.Get("Authorization") != "" {
+		return true
+	}
+	for _, name := range c.authCookies {
+		if _, err := req.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// serveEntry writes a cached response to the client
+func serveEntry(ctx context.Context, w http.ResponseWriter, e *entry, age time.Duration, status string) {
+	header := w.Header()
+	CopyHeader(header, e.Header.Clone())
+	header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	header.Set(CacheStatusHeader, status)
+	w.WriteHeader(e.StatusCode)
+	if _, err := w.Write(e.Body); err != nil {
+		log.Error(ctx, "error writing cached response", err)
+	}
+}
+
+// requestKey identifies a request in the cache. It starts with the path so that purging by path prefix
+// can find all the keys for a section of the site.
+func requestKey(req *http.Request) string {
+	return KeyPrefix + req.URL.RequestURI() + "#" + req.Method + "#" + req.Host
+}
+
+// variantKey identifies the variant of a response that matches the request's values for the vary headers
+func variantKey(key string, vary []string, header http.Header) string {
+	var b strings.Builder
+	b.WriteString(key)
+	b.WriteString(variantMarker)
+	for _, name := range vary {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(header.Values(name), ","))
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// varyHeaders returns the canonical, sorted names of the headers listed in the response's Vary header
+func varyHeaders(header http.Header) []string {
+	vary := []string{}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(vary)
+	return vary
+}
@@ -0,0 +1,369 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients/mock"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const maxBodySize = 1024
+
+func TestCacheHandler(t *testing.T) {
+	Convey("Given a cache in front of an upstream handler", t, func() {
+		now := time.Now()
+		var (
+			calls        atomic.Int32
+			status       = http.StatusOK
+			cacheControl = "public, max-age=60"
+		)
+		upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Cache-Control", cacheControl)
+			w.Header().Set("Vary", "Accept-Language")
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("body for " + r.Header.Get("Accept-Language")))
+		})
+
+		store := NewMemoryStore(100, 1024*1024)
+		store.now = func() time.Time { return now }
+		c := NewWithStore(store, config.CacheRules{
+			{PathPrefix: "/private", Disabled: true},
+		}, []string{"access_token"}, maxBodySize)
+		c.now = func() time.Time { return now }
+		handler := c.Handler(upstream)
+
+		get := func(path, language string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+			req.Header.Set("Accept-Language", language)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When a page is requested with an auth cookie or an Authorization header", func() {
+			get("/economy", "en")
+			stored, err := store.Keys(context.Background(), KeyPrefix)
+			So(err, ShouldBeNil)
+			withCookie := httptest.NewRequest(http.MethodGet, "/economy", http.NoBody)
+			withCookie.Header.Set("Accept-Language", "en")
+			withCookie.AddCookie(&http.Cookie{Name: "access_token", Value: "token"})
+			withHeader := httptest.NewRequest(http.MethodGet, "/economy", http.NoBody)
+			withHeader.Header.Set("Accept-Language", "en")
+			withHeader.Header.Set("Authorization", "Bearer token")
+			cookieResponse, headerResponse := httptest.NewRecorder(), httptest.NewRecorder()
+			handler.ServeHTTP(cookieResponse, withCookie)
+			handler.ServeHTTP(headerResponse, withHeader)
+
+			Convey("Then the cache is bypassed", func() {
+				So(calls.Load(), ShouldEqual, 3)
+				So(cookieResponse.Header().Get(CacheStatusHeader), ShouldBeEmpty)
+				So(headerResponse.Header().Get(CacheStatusHeader), ShouldBeEmpty)
+			})
+
+			Convey("And their responses aren't stored", func() {
+				keys, err := store.Keys(context.Background(), KeyPrefix)
+				So(err, ShouldBeNil)
+				So(keys, ShouldHaveLength, len(stored))
+			})
+		})
+
+		Convey("When the same page is requested twice", func() {
+			first := get("/economy", "en")
+			second := get("/economy", "en")
+
+			Convey("Then the second response is served from the cache", func() {
+				So(calls.Load(), ShouldEqual, 1)
+				So(first.Header().Get(CacheStatusHeader), ShouldEqual, cacheStatusMiss)
+				So(second.Header().Get(CacheStatusHeader), ShouldEqual, cacheStatusHit)
+				So(second.Code, ShouldEqual, http.StatusOK)
+				So(second.Body.String(), ShouldEqual, "body for en")
+			})
+		})
+
+		Convey("When the page is requested with a different value for a Vary header", func() {
+			get("/economy", "en")
+			w := get("/economy", "cy")
+
+			Convey("Then a separate variant is fetched", func() {
+				So(calls.Load(), ShouldEqual, 2)
+				So(w.Body.String(), ShouldEqual, "body for cy")
+			})
+		})
+
+		Convey("When a page under a disabled rule is requested twice", func() {
+			get("/private/page", "en")
+			get("/private/page", "en")
+
+			Convey("Then both requests go to the upstream", func() {
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the upstream says the response must not be stored", func() {
+			cacheControl = "no-store"
+			get("/economy", "en")
+			get("/economy", "en")
+
+			Convey("Then both requests go to the upstream", func() {
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When a stale page is requested within its stale-while-revalidate window", func() {
+			cacheControl = "max-age=60, stale-while-revalidate=60"
+			get("/economy", "en")
+			now = now.Add(90 * time.Second)
+			w := get("/economy", "en")
+
+			Convey("Then the stale response is served and refreshed in the background", func() {
+				So(w.Header().Get(CacheStatusHeader), ShouldEqual, cacheStatusStale)
+				So(w.Header().Get("Age"), ShouldEqual, "90")
+				So(waitFor(func() bool { return calls.Load() == 2 }), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the upstream fails within a page's stale-if-error window", func() {
+			cacheControl = "max-age=60, stale-if-error=600"
+			get("/economy", "en")
+			now = now.Add(5 * time.Minute)
+			status = http.StatusBadGateway
+			w := get("/economy", "en")
+
+			Convey("Then the stale response is served instead of the error", func() {
+				So(calls.Load(), ShouldEqual, 2)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get(CacheStatusHeader), ShouldEqual, cacheStatusStale)
+				So(w.Body.String(), ShouldEqual, "body for en")
+			})
+		})
+
+		Convey("When the upstream fails after the stale-if-error window", func() {
+			cacheControl = "max-age=60, stale-if-error=60"
+			get("/economy", "en")
+			now = now.Add(5 * time.Minute)
+			status = http.StatusBadGateway
+			w := get("/economy", "en")
+
+			Convey("Then the error is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusBadGateway)
+			})
+		})
+
+		Convey("When a section of the site is purged", func() {
+			get("/economy/inflation", "en")
+			get("/economy/gdp", "cy")
+			get("/people", "en")
+
+			purged, err := c.Purge(context.Background(), "/economy")
+			So(err, ShouldBeNil)
+
+			Convey("Then only responses for that section are removed", func() {
+				So(purged, ShouldEqual, 2)
+				So(get("/economy/inflation", "en").Header().Get(CacheStatusHeader), ShouldEqual, cacheStatusMiss)
+				So(get("/people", "en").Header().Get(CacheStatusHeader), ShouldEqual, cacheStatusHit)
+			})
+		})
+	})
+}
+
+// waitFor polls condition until it is true or a second has passed
+func waitFor(condition func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestResponseFreshness(t *testing.T) {
+	Convey("Given a GET request", t, func() {
+		now := time.Now()
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+
+		Convey("When the response has s-maxage and max-age", func() {
+			header := http.Header{"Cache-Control": {"max-age=60, s-maxage=300"}}
+			f, ok := responseFreshness(req, http.StatusOK, header, nil, now)
+
+			Convey("Then s-maxage is used", func() {
+				So(ok, ShouldBeTrue)
+				So(f.fresh, ShouldEqual, 300*time.Second)
+			})
+		})
+
+		Convey("When the response only has an Expires header", func() {
+			header := http.Header{
+				"Date":    {now.UTC().Format(http.TimeFormat)},
+				"Expires": {now.Add(2 * time.Minute).UTC().Format(http.TimeFormat)},
+			}
+			f, ok := responseFreshness(req, http.StatusOK, header, nil, now)
+
+			Convey("Then the freshness is the difference from the Date header", func() {
+				So(ok, ShouldBeTrue)
+				So(f.fresh, ShouldEqual, 2*time.Minute)
+			})
+		})
+
+		Convey("When the response has no freshness information but a rule gives a TTL", func() {
+			rule := &config.CacheRule{PathPrefix: "/", TTL: config.Duration(time.Minute), StaleIfError: config.Duration(time.Hour)}
+			f, ok := responseFreshness(req, http.StatusOK, http.Header{}, rule, now)
+
+			Convey("Then the rule is used", func() {
+				So(ok, ShouldBeTrue)
+				So(f.fresh, ShouldEqual, time.Minute)
+				So(f.staleIfError, ShouldEqual, time.Hour)
+				So(f.storageTTL(), ShouldEqual, time.Hour+time.Minute)
+			})
+		})
+
+		Convey("When the response sets a cookie", func() {
+			header := http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=b"}}
+			_, ok := responseFreshness(req, http.StatusOK, header, nil, now)
+
+			Convey("Then it is not cacheable", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the response has an uncacheable status", func() {
+			header := http.Header{"Cache-Control": {"max-age=60"}}
+			_, ok := responseFreshness(req, http.StatusInternalServerError, header, nil, now)
+
+			Convey("Then it is not cacheable", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the request is authorised and the response is not explicitly public", func() {
+			req.Header.Set("Authorization", "Bearer token")
+			header := http.Header{"Cache-Control": {"max-age=60"}}
+			_, ok := responseFreshness(req, http.StatusOK, header, nil, now)
+
+			Convey("Then it is not cacheable", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	Convey("Given a memory store with room for two entries", t, func() {
+		ctx := context.Background()
+		now := time.Now()
+		store := NewMemoryStore(2, 1024)
+		store.now = func() time.Time { return now }
+
+		So(store.Set(ctx, "a", []byte("1"), time.Minute), ShouldBeNil)
+		So(store.Set(ctx, "b", []byte("2"), time.Minute), ShouldBeNil)
+
+		Convey("When a third entry is added after the first is read", func() {
+			_, err := store.Get(ctx, "a")
+			So(err, ShouldBeNil)
+			So(store.Set(ctx, "c", []byte("3"), time.Minute), ShouldBeNil)
+
+			Convey("Then the least recently used entry is evicted", func() {
+				_, err := store.Get(ctx, "b")
+				So(err, ShouldEqual, ErrNotFound)
+				value, err := store.Get(ctx, "a")
+				So(err, ShouldBeNil)
+				So(string(value), ShouldEqual, "1")
+			})
+		})
+
+		Convey("When an entry has expired", func() {
+			now = now.Add(2 * time.Minute)
+
+			Convey("Then it is not returned", func() {
+				_, err := store.Get(ctx, "a")
+				So(err, ShouldEqual, ErrNotFound)
+			})
+		})
+	})
+
+	Convey("Given a memory store with room for many entries but only 20 bytes", t, func() {
+		ctx := context.Background()
+		store := NewMemoryStore(100, 20)
+
+		So(store.Set(ctx, "a", []byte("123456789"), time.Minute), ShouldBeNil)
+		So(store.Set(ctx, "b", []byte("123456789"), time.Minute), ShouldBeNil)
+
+		Convey("When an entry is added that takes the total over the limit", func() {
+			So(store.Set(ctx, "c", []byte("1234"), time.Minute), ShouldBeNil)
+
+			Convey("Then the least recently used entries are evicted until it fits", func() {
+				_, err := store.Get(ctx, "a")
+				So(err, ShouldEqual, ErrNotFound)
+				_, err = store.Get(ctx, "b")
+				So(err, ShouldBeNil)
+				_, err = store.Get(ctx, "c")
+				So(err, ShouldBeNil)
+				So(store.size, ShouldEqual, 15)
+			})
+		})
+
+		Convey("When an entry is replaced with a larger value", func() {
+			So(store.Set(ctx, "b", []byte("1234567890"), time.Minute), ShouldBeNil)
+
+			Convey("Then only the size of the new value is held for it", func() {
+				So(store.size, ShouldEqual, 11)
+				_, err := store.Get(ctx, "a")
+				So(err, ShouldEqual, ErrNotFound)
+			})
+		})
+
+		Convey("When an entry is larger than the whole store", func() {
+			So(store.Set(ctx, "d", make([]byte, 20), time.Minute), ShouldBeNil)
+
+			Convey("Then it isn't kept, and the other entries aren't evicted for it", func() {
+				_, err := store.Get(ctx, "d")
+				So(err, ShouldEqual, ErrNotFound)
+				keys, err := store.Keys(ctx, "")
+				So(err, ShouldBeNil)
+				So(keys, ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When an entry is deleted", func() {
+			So(store.Delete(ctx, "a"), ShouldBeNil)
+
+			Convey("Then its size is released", func() {
+				So(store.size, ShouldEqual, 10)
+			})
+		})
+	})
+}
+
+func TestRedisStore(t *testing.T) {
+	Convey("Given a Redis store holding cached responses over two pages of a scan", t, func() {
+		ctx := context.Background()
+		redisMock := &mock.RedisMock{
+			ScanKeysFunc: func(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error {
+				if err := fn([]string{"cache:/economy"}); err != nil {
+					return err
+				}
+				return fn([]string{"cache:/people"})
+			},
+		}
+		store := NewRedisStore(redisMock)
+
+		Convey("When the keys under a prefix are listed", func() {
+			keys, err := store.Keys(ctx, KeyPrefix)
+
+			Convey("Then every page of keys is returned, without reading the cached responses", func() {
+				So(err, ShouldBeNil)
+				So(keys, ShouldResemble, []string{"cache:/economy", "cache:/people"})
+				So(redisMock.ScanKeysCalls()[0].MatchPattern, ShouldEqual, "cache:*")
+				So(redisMock.GetKeyValuePairsCalls(), ShouldBeEmpty)
+			})
+		})
+	})
+}
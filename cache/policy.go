@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+)
+
+// cacheableStatusCodes are the response status codes that may be stored
+var cacheableStatusCodes = []int{
+	http.StatusOK,
+	http.StatusNonAuthoritativeInfo,
+	http.StatusNoContent,
+	http.StatusMultipleChoices,
+	http.StatusMovedPermanently,
+	http.StatusPermanentRedirect,
+	http.StatusNotFound,
+	http.StatusGone,
+}
+
+// freshness describes how long a response may be served from the cache
+type freshness struct {
+	fresh                time.Duration
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+}
+
+// storageTTL is how long the response needs to be kept for, including the time it may be served stale
+func (f freshness) storageTTL() time.Duration {
+	return f.fresh + max(f.staleWhileRevalidate, f.staleIfError)
+}
+
+// parseCacheControl parses a Cache-Control header into a map of lower case directive names to values
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+// seconds parses a delta-seconds directive value
+func seconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// responseFreshness works out whether a response can be stored and for how long, based on its headers and
+// the matching cache rule. It returns false if the response must not be stored.
+func responseFreshness(req *http.Request, statusCode int, header http.Header, rule *config.CacheRule, now time.Time) (freshness, bool) {
+	if !slices.Contains(cacheableStatusCodes, statusCode) {
+		return freshness{}, false
+	}
+	if header.Get("Set-Cookie") != "" || strings.TrimSpace(header.Get("Vary")) == "*" {
+		return freshness{}, false
+	}
+
+	directives := parseCacheControl(header)
+	for _, name := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[name]; ok {
+			return freshness{}, false
+		}
+	}
+
+	_, public := directives["public"]
+	_, hasSMaxAge := seconds(directives, "s-maxage")
+	if req.Header.Get("Authorization") != "" && !public && !hasSMaxAge {
+		return freshness{}, false
+	}
+
+	f := freshness{fresh: freshLifetime(header, directives, rule, now)}
+
+	if swr, ok := seconds(directives, "stale-while-revalidate"); ok {
+		f.staleWhileRevalidate = swr
+	} else if rule != nil {
+		f.staleWhileRevalidate = time.Duration(rule.StaleWhileRevalidate)
+	}
+	if sie, ok := seconds(directives, "stale-if-error"); ok {
+		f.staleIfError = sie
+	} else if rule != nil {
+		f.staleIfError = time.Duration(rule.StaleIfError)
+	}
+
+	if f.fresh <= 0 && f.staleIfError <= 0 && f.staleWhileRevalidate <= 0 {
+		return freshness{}, false
+	}
+	return f, true
+}
+
+// freshLifetime returns how long a response is fresh for, from s-maxage, max-age or Expires, falling back to the
+// TTL of the matching rule
+func freshLifetime(header http.Header, directives map[string]string, rule *config.CacheRule, now time.Time) time.Duration {
+	if sMaxAge, ok := seconds(directives, "s-maxage"); ok {
+		return sMaxAge
+	}
+	if maxAge, ok := seconds(directives, "max-age"); ok {
+		return maxAge
+	}
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		date, dateErr := http.ParseTime(header.Get("Date"))
+		if dateErr != nil {
+			date = now
+		}
+		return max(expires.Sub(date), 0)
+	}
+	if rule != nil {
+		return time.Duration(rule.TTL)
+	}
+	return 0
+}
+
+// matchRule returns the rule with the longest path prefix matching path, or nil if none match
+func matchRule(rules config.CacheRules, path string) *config.CacheRule {
+	var match *config.CacheRule
+	for i := range rules {
+		rule := &rules[i]
+		if strings.HasPrefix(path, rule.PathPrefix) && (match == nil || len(rule.PathPrefix) > len(match.PathPrefix)) {
+			match = rule
+		}
+	}
+	return match
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	disRedis "github.com/ONSdigital/dis-redis"
+)
+
+// redisScanCount is the number of keys requested per SCAN iteration when listing keys in Redis
+const redisScanCount = 1000
+
+// ErrNotFound is returned by a Store when there is no value for a key
+var ErrNotFound = errors.New("cache entry not found")
+
+// Store is the storage backend for cached responses
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// MemoryStore is an in-process Store that evicts the least recently used entries once it holds more than maxEntries,
+// or more than maxBytes in total
+type MemoryStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	size       int64
+	items      map[string]*list.Element
+	lru        *list.List
+	now        func() time.Time
+}
+
+type memoryItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// size returns the number of bytes held for the item
+func (item *memoryItem) size() int64 {
+	return int64(len(item.key) + len(item.value))
+}
+
+// NewMemoryStore creates a MemoryStore holding at most maxEntries values, with keys and values of at most maxBytes
+// in total
+func NewMemoryStore(maxEntries int, maxBytes int64) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	item := elem.Value.(*memoryItem)
+	if s.now().After(item.expires) {
+		s.remove(elem)
+		return nil, ErrNotFound
+	}
+	s.lru.MoveToFront(elem)
+	return item.value, nil
+}
+
+// Set implements Store
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item := &memoryItem{key: key, value: value, expires: s.now().Add(ttl)}
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+	// a value larger than the whole store isn't kept, rather than evicting every other value for it
+	if s.maxBytes > 0 && item.size() > s.maxBytes {
+		return nil
+	}
+
+	s.items[key] = s.lru.PushFront(item)
+	s.size += item.size()
+	for (s.maxEntries > 0 && s.lru.Len() > s.maxEntries) || (s.maxBytes > 0 && s.size > s.maxBytes) {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.remove(elem)
+	}
+	return nil
+}
+
+// Keys implements Store
+func (s *MemoryStore) Keys(_ context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *MemoryStore) remove(elem *list.Element) {
+	item := elem.Value.(*memoryItem)
+	s.lru.Remove(elem)
+	delete(s.items, item.key)
+	s.size -= item.size()
+}
+
+// RedisStore is a Store backed by Redis, so that cached responses are shared between proxy instances
+type RedisStore struct {
+	client clients.Redis
+}
+
+// NewRedisStore creates a RedisStore using the given client
+func NewRedisStore(client clients.Redis) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Get implements Store
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.GetValue(ctx, key)
+	if errors.Is(err, disRedis.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// Set implements Store
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.SetValue(ctx, key, value, ttl)
+}
+
+// Delete implements Store
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	if err := s.client.DeleteValue(ctx, key); err != nil && !errors.Is(err, disRedis.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Keys implements Store
+func (s *RedisStore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := s.client.ScanKeys(ctx, clients.EscapeGlob(prefix)+"*", redisScanCount, func(page []string) error {
+		keys = append(keys, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// CaptureWriter passes a response through to the client while keeping a copy of it, up to a size limit, so
+// that it can be stored or shared. If suppress returns true for the status code, nothing is passed through, which lets
+// the caller serve something else instead (e.g. a stale response in place of an upstream error).
+type CaptureWriter struct {
+	rw          http.ResponseWriter
+	header      http.Header
+	statusCode  int
+	wroteHeader bool
+	suppress    func(statusCode int) bool
+	suppressed  bool
+	body        bytes.Buffer
+	limit       int64
+	overflow    bool
+}
+
+// NewCaptureWriter creates a CaptureWriter that passes the response through to rw, keeping a copy of a body of up
+// to limit bytes. suppress may be nil if the response is always passed through.
+func NewCaptureWriter(rw http.ResponseWriter, limit int64, suppress func(statusCode int) bool) *CaptureWriter {
+	return &CaptureWriter{
+		rw:       rw,
+		header:   http.Header{},
+		suppress: suppress,
+		limit:    limit,
+	}
+}
+
+// Header implements http.ResponseWriter
+func (cw *CaptureWriter) Header() http.Header {
+	return cw.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (cw *CaptureWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	if statusCode < http.StatusOK {
+		// informational responses are passed straight through
+		CopyHeader(cw.rw.Header(), cw.header)
+		cw.rw.WriteHeader(statusCode)
+		return
+	}
+
+	cw.wroteHeader = true
+	cw.statusCode = statusCode
+	if cw.suppress != nil && cw.suppress(statusCode) {
+		cw.suppressed = true
+		return
+	}
+	CopyHeader(cw.rw.Header(), cw.header)
+	cw.rw.WriteHeader(statusCode)
+}
+
+// Write implements http.ResponseWriter
+func (cw *CaptureWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.suppressed {
+		return len(b), nil
+	}
+	if !cw.overflow {
+		if int64(cw.body.Len()+len(b)) > cw.limit {
+			cw.overflow = true
+			cw.body = bytes.Buffer{}
+		} else {
+			cw.body.Write(b)
+		}
+	}
+	return cw.rw.Write(b)
+}
+
+// Flush implements http.Flusher, so that streamed upstream responses are still flushed to the client
+func (cw *CaptureWriter) Flush() {
+	if cw.suppressed {
+		return
+	}
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(cw.rw).Flush()
+}
+
+// StatusCode returns the status code of the response, or 0 if it hasn't been written
+func (cw *CaptureWriter) StatusCode() int {
+	return cw.statusCode
+}
+
+// Body returns the copy of the response body, and false if no response was written or it was too large to keep
+func (cw *CaptureWriter) Body() ([]byte, bool) {
+	if !cw.wroteHeader || cw.overflow {
+		return nil, false
+	}
+	return cw.body.Bytes(), true
+}
+
+// CopyHeader sets each header in src on dst, replacing any values dst already has for it
+func CopyHeader(dst, src http.Header) {
+	for name, values := range src {
+		dst[name] = values
+	}
+}
+
+// DiscardWriter is a ResponseWriter that throws the response away, used for fetches that no client is waiting for
+type DiscardWriter struct {
+	header http.Header
+}
+
+// Header implements http.ResponseWriter
+func (w *DiscardWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+
+// Write implements http.ResponseWriter
+func (w *DiscardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *DiscardWriter) WriteHeader(int) {}
@@ -2,8 +2,11 @@ package clients
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/redis/go-redis/v9"
 )
 
 //go:generate moq -out mock/redis.go -pkg mock . Redis
@@ -11,5 +14,30 @@ import (
 // Redis defines the required methods for Redis
 type Redis interface {
 	Checker(ctx context.Context, state *healthcheck.CheckState) error
+	Close(ctx context.Context) error
 	GetValue(ctx context.Context, key string) (string, error)
+	SetValue(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	DeleteValue(ctx context.Context, key string) error
+	GetKeyValuePairs(ctx context.Context, matchPattern string, count int64, cursor uint64) (keyValuePairs map[string]string, newCursor uint64, err error)
+	ScanKeys(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error
+	RenameNX(ctx context.Context, key, newKey string) (bool, error)
+	GetList(ctx context.Context, key string) ([]string, error)
+	Transaction(ctx context.Context, commands ...Command) error
+	RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// Command is a Redis command and its arguments, such as Command{"RPUSH", "list", "value"}
+type Command []interface{}
+
+// EscapeGlob escapes the characters that have a special meaning in Redis match patterns
+func EscapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
@@ -0,0 +1,246 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrRedisNotConnected is returned by a RedisConnection until it has connected to Redis
+var ErrRedisNotConnected = errors.New("redis is not connected")
+
+// RedisConnection is a Redis client that connects in the background, so that the proxy can start while Redis is
+// unavailable. Until it has connected, every call fails with ErrRedisNotConnected and the health check is a
+// warning, as the proxy still serves requests without Redis. Once connected it passes calls to the client, which reconnects by itself if the connection is lost.
+type RedisConnection struct {
+	connect        func(ctx context.Context) (Redis, error)
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	client   atomic.Pointer[Redis]
+	lastErr  atomic.Pointer[error]
+	started  atomic.Bool
+	stop     chan struct{}
+	stopOnce sync.Once
+	// done is closed once the connection has stopped trying to connect
+	done chan struct{}
+}
+
+// NewRedisConnection creates a RedisConnection that connects with connect, waiting initialBackoff after the first
+// failed attempt and doubling the wait after each further attempt, up to maxBackoff
+func NewRedisConnection(connect func(ctx context.Context) (Redis, error), initialBackoff, maxBackoff time.Duration) *RedisConnection {
+	return &RedisConnection{
+		connect:        connect,
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+}
+
+// Start makes the first attempt to connect, so that Redis is used straight away if it is available, and keeps
+// trying in the background if that attempt fails
+func (c *RedisConnection) Start(ctx context.Context) {
+	c.started.Store(true)
+	if c.tryConnect(ctx) {
+		close(c.done)
+		return
+	}
+	log.Warn(ctx, "redis is unavailable, starting without it and retrying in the background",
+		log.Data{"error": c.lastError().Error()})
+
+	go func() {
+		defer close(c.done)
+		backoff := c.initialBackoff
+		for {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+				if c.tryConnect(ctx) {
+					log.Info(ctx, "connected to redis")
+					return
+				}
+				log.Warn(ctx, "failed to connect to redis, retrying",
+					log.Data{"error": c.lastError().Error(), "backoff": backoff.String()})
+				backoff = min(2*backoff, c.maxBackoff)
+			case <-c.stop:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// tryConnect creates a client and checks that Redis can be reached with it, returning true if it can
+func (c *RedisConnection) tryConnect(ctx context.Context) bool {
+	client, err := c.connect(ctx)
+	if err != nil {
+		c.lastErr.Store(&err)
+		return false
+	}
+
+	state := healthcheck.NewCheckState("redis")
+	if err := client.Checker(ctx, state); err != nil || state.Status() != healthcheck.StatusOK {
+		if err == nil {
+			err = errors.New(state.Message())
+		}
+		c.lastErr.Store(&err)
+		_ = client.Close(ctx)
+		return false
+	}
+
+	c.client.Store(&client)
+	return true
+}
+
+// Connected returns whether Redis has been connected to
+func (c *RedisConnection) Connected() bool {
+	return c.client.Load() != nil
+}
+
+// lastError returns the error from the last failed attempt to connect, or ErrRedisNotConnected if there hasn't
+// been one
+func (c *RedisConnection) lastError() error {
+	if err := c.lastErr.Load(); err != nil {
+		return *err
+	}
+	return ErrRedisNotConnected
+}
+
+// get returns the client, or ErrRedisNotConnected if Redis hasn't been connected to yet
+func (c *RedisConnection) get() (Redis, error) {
+	client := c.client.Load()
+	if client == nil {
+		return nil, ErrRedisNotConnected
+	}
+	return *client, nil
+}
+
+// PoolStats returns the stats of the client's connection pool, or nil if Redis hasn't been connected to or the
+// client doesn't report them
+func (c *RedisConnection) PoolStats() *redis.PoolStats {
+	client, err := c.get()
+	if err != nil {
+		return nil
+	}
+	if pooled, ok := client.(interface{ PoolStats() *redis.PoolStats }); ok {
+		return pooled.PoolStats()
+	}
+	return nil
+}
+
+// Checker implements healthcheck.Checker, reporting a warning until Redis has been connected to
+func (c *RedisConnection) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	client, err := c.get()
+	if err != nil {
+		return state.Update(healthcheck.StatusWarning, fmt.Sprintf("%s: %s", ErrRedisNotConnected, c.lastError()), 0)
+	}
+	return client.Checker(ctx, state)
+}
+
+// Close stops trying to connect, and closes the client if Redis has been connected to
+func (c *RedisConnection) Close(ctx context.Context) error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	if c.started.Load() {
+		// wait for an attempt to connect that is in progress, so that its client is closed too
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	client, err := c.get()
+	if err != nil {
+		return nil
+	}
+	return client.Close(ctx)
+}
+
+// GetValue implements Redis
+func (c *RedisConnection) GetValue(ctx context.Context, key string) (string, error) {
+	client, err := c.get()
+	if err != nil {
+		return "", err
+	}
+	return client.GetValue(ctx, key)
+}
+
+// SetValue implements Redis
+func (c *RedisConnection) SetValue(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	client, err := c.get()
+	if err != nil {
+		return err
+	}
+	return client.SetValue(ctx, key, value, expiration)
+}
+
+// DeleteValue implements Redis
+func (c *RedisConnection) DeleteValue(ctx context.Context, key string) error {
+	client, err := c.get()
+	if err != nil {
+		return err
+	}
+	return client.DeleteValue(ctx, key)
+}
+
+// GetKeyValuePairs implements Redis
+func (c *RedisConnection) GetKeyValuePairs(ctx context.Context, matchPattern string, count int64, cursor uint64) (keyValuePairs map[string]string, newCursor uint64, err error) {
+	client, err := c.get()
+	if err != nil {
+		return nil, 0, err
+	}
+	return client.GetKeyValuePairs(ctx, matchPattern, count, cursor)
+}
+
+// ScanKeys implements Redis
+func (c *RedisConnection) ScanKeys(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error {
+	client, err := c.get()
+	if err != nil {
+		return err
+	}
+	return client.ScanKeys(ctx, matchPattern, count, fn)
+}
+
+// RenameNX implements Redis
+func (c *RedisConnection) RenameNX(ctx context.Context, key, newKey string) (bool, error) {
+	client, err := c.get()
+	if err != nil {
+		return false, err
+	}
+	return client.RenameNX(ctx, key, newKey)
+}
+
+// GetList implements Redis
+func (c *RedisConnection) GetList(ctx context.Context, key string) ([]string, error) {
+	client, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	return client.GetList(ctx, key)
+}
+
+// Transaction implements Redis
+func (c *RedisConnection) Transaction(ctx context.Context, commands ...Command) error {
+	client, err := c.get()
+	if err != nil {
+		return err
+	}
+	return client.Transaction(ctx, commands...)
+}
+
+// RunScript implements Redis
+func (c *RedisConnection) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	client, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	return client.RunScript(ctx, script, keys, args...)
+}
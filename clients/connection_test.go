@@ -0,0 +1,153 @@
+package clients_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/ONSdigital/dis-redirect-proxy/clients/mock"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/redis/go-redis/v9"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRedisConnection(t *testing.T) {
+	Convey("Given a redis server", t, func() {
+		ctx := context.Background()
+		var healthy atomic.Bool
+		healthy.Store(true)
+		var closed atomic.Int32
+		redisMock := &mock.RedisMock{
+			CheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error {
+				if !healthy.Load() {
+					return state.Update(healthcheck.StatusCritical, "connection refused", 500)
+				}
+				return state.Update(healthcheck.StatusOK, "redis is healthy", 200)
+			},
+			GetValueFunc: func(ctx context.Context, key string) (string, error) { return "/new", nil },
+			CloseFunc: func(ctx context.Context) error {
+				closed.Add(1)
+				return nil
+			},
+		}
+		var attempts atomic.Int32
+		connect := func(ctx context.Context) (clients.Redis, error) {
+			attempts.Add(1)
+			return redisMock, nil
+		}
+
+		Convey("When it is available on startup", func() {
+			conn := clients.NewRedisConnection(connect, time.Millisecond, time.Millisecond)
+			conn.Start(ctx)
+
+			Convey("Then it is connected to straight away and calls are passed to it", func() {
+				So(conn.Connected(), ShouldBeTrue)
+				value, err := conn.GetValue(ctx, "/old")
+				So(err, ShouldBeNil)
+				So(value, ShouldEqual, "/new")
+			})
+
+			Convey("And its client is closed when the connection is closed", func() {
+				So(conn.Close(ctx), ShouldBeNil)
+				So(closed.Load(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When it is unavailable on startup", func() {
+			healthy.Store(false)
+			conn := clients.NewRedisConnection(connect, 10*time.Millisecond, 20*time.Millisecond)
+			conn.Start(ctx)
+			Reset(func() { _ = conn.Close(ctx) })
+
+			Convey("Then it isn't connected and calls fail", func() {
+				So(conn.Connected(), ShouldBeFalse)
+				_, err := conn.GetValue(ctx, "/old")
+				So(err, ShouldEqual, clients.ErrRedisNotConnected)
+				So(closed.Load(), ShouldBeGreaterThanOrEqualTo, 1)
+			})
+
+			Convey("And the health check is a warning", func() {
+				state := healthcheck.NewCheckState("Redis")
+				So(conn.Checker(ctx, state), ShouldBeNil)
+				So(state.Status(), ShouldEqual, healthcheck.StatusWarning)
+				So(state.Message(), ShouldEqual, "redis is not connected: connection refused")
+			})
+
+			Convey("And it is connected to once it becomes available", func() {
+				healthy.Store(true)
+				So(eventually(conn.Connected), ShouldBeTrue)
+				So(attempts.Load(), ShouldBeGreaterThan, 1)
+
+				state := healthcheck.NewCheckState("Redis")
+				So(conn.Checker(ctx, state), ShouldBeNil)
+				So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+			})
+
+			Convey("And it stops trying to connect once it is closed", func() {
+				So(conn.Close(ctx), ShouldBeNil)
+				tried := attempts.Load()
+				time.Sleep(50 * time.Millisecond)
+				So(attempts.Load(), ShouldEqual, tried)
+			})
+		})
+
+		Convey("When its client doesn't report pool stats", func() {
+			conn := clients.NewRedisConnection(connect, time.Millisecond, time.Millisecond)
+			conn.Start(ctx)
+
+			Convey("Then no pool stats are reported", func() {
+				So(conn.PoolStats(), ShouldBeNil)
+			})
+		})
+
+		Convey("When its client reports pool stats", func() {
+			conn := clients.NewRedisConnection(func(ctx context.Context) (clients.Redis, error) {
+				return &pooledRedis{RedisMock: redisMock, stats: &redis.PoolStats{TotalConns: 3, IdleConns: 1}}, nil
+			}, time.Millisecond, time.Millisecond)
+			conn.Start(ctx)
+
+			Convey("Then they are returned", func() {
+				So(conn.PoolStats(), ShouldResemble, &redis.PoolStats{TotalConns: 3, IdleConns: 1})
+			})
+		})
+
+		Convey("When a client can't be created", func() {
+			conn := clients.NewRedisConnection(func(ctx context.Context) (clients.Redis, error) {
+				return nil, errors.New("invalid credentials")
+			}, time.Hour, time.Hour)
+			conn.Start(ctx)
+			Reset(func() { _ = conn.Close(ctx) })
+
+			Convey("Then the error is reported by the health check", func() {
+				state := healthcheck.NewCheckState("Redis")
+				So(conn.Checker(ctx, state), ShouldBeNil)
+				So(state.Message(), ShouldEqual, "redis is not connected: invalid credentials")
+			})
+		})
+	})
+}
+
+// pooledRedis is a Redis client that reports the stats of its connection pool
+type pooledRedis struct {
+	*mock.RedisMock
+	stats *redis.PoolStats
+}
+
+func (p *pooledRedis) PoolStats() *redis.PoolStats {
+	return p.stats
+}
+
+// eventually returns whether condition becomes true within a second
+func eventually(condition func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
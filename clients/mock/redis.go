@@ -7,7 +7,9 @@ import (
 	"context"
 	"github.com/ONSdigital/dis-redirect-proxy/clients"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/redis/go-redis/v9"
 	"sync"
+	"time"
 )
 
 // Ensure, that RedisMock does implement clients.Redis.
@@ -23,9 +25,36 @@ var _ clients.Redis = &RedisMock{}
 //			CheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error {
 //				panic("mock out the Checker method")
 //			},
+//			CloseFunc: func(ctx context.Context) error {
+//				panic("mock out the Close method")
+//			},
+//			DeleteValueFunc: func(ctx context.Context, key string) error {
+//				panic("mock out the DeleteValue method")
+//			},
+//			GetKeyValuePairsFunc: func(ctx context.Context, matchPattern string, count int64, cursor uint64) (map[string]string, uint64, error) {
+//				panic("mock out the GetKeyValuePairs method")
+//			},
+//			GetListFunc: func(ctx context.Context, key string) ([]string, error) {
+//				panic("mock out the GetList method")
+//			},
 //			GetValueFunc: func(ctx context.Context, key string) (string, error) {
 //				panic("mock out the GetValue method")
 //			},
+//			RenameNXFunc: func(ctx context.Context, key string, newKey string) (bool, error) {
+//				panic("mock out the RenameNX method")
+//			},
+//			RunScriptFunc: func(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+//				panic("mock out the RunScript method")
+//			},
+//			ScanKeysFunc: func(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error {
+//				panic("mock out the ScanKeys method")
+//			},
+//			SetValueFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+//				panic("mock out the SetValue method")
+//			},
+//			TransactionFunc: func(ctx context.Context, commands ...clients.Command) error {
+//				panic("mock out the Transaction method")
+//			},
 //		}
 //
 //		// use mockedRedis in code that requires clients.Redis
@@ -36,9 +65,36 @@ type RedisMock struct {
 	// CheckerFunc mocks the Checker method.
 	CheckerFunc func(ctx context.Context, state *healthcheck.CheckState) error
 
+	// CloseFunc mocks the Close method.
+	CloseFunc func(ctx context.Context) error
+
+	// DeleteValueFunc mocks the DeleteValue method.
+	DeleteValueFunc func(ctx context.Context, key string) error
+
+	// GetKeyValuePairsFunc mocks the GetKeyValuePairs method.
+	GetKeyValuePairsFunc func(ctx context.Context, matchPattern string, count int64, cursor uint64) (map[string]string, uint64, error)
+
+	// GetListFunc mocks the GetList method.
+	GetListFunc func(ctx context.Context, key string) ([]string, error)
+
 	// GetValueFunc mocks the GetValue method.
 	GetValueFunc func(ctx context.Context, key string) (string, error)
 
+	// RenameNXFunc mocks the RenameNX method.
+	RenameNXFunc func(ctx context.Context, key string, newKey string) (bool, error)
+
+	// RunScriptFunc mocks the RunScript method.
+	RunScriptFunc func(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error)
+
+	// ScanKeysFunc mocks the ScanKeys method.
+	ScanKeysFunc func(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error
+
+	// SetValueFunc mocks the SetValue method.
+	SetValueFunc func(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+
+	// TransactionFunc mocks the Transaction method.
+	TransactionFunc func(ctx context.Context, commands ...clients.Command) error
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Checker holds details about calls to the Checker method.
@@ -48,6 +104,36 @@ type RedisMock struct {
 			// State is the state argument value.
 			State *healthcheck.CheckState
 		}
+		// Close holds details about calls to the Close method.
+		Close []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// DeleteValue holds details about calls to the DeleteValue method.
+		DeleteValue []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
+		// GetKeyValuePairs holds details about calls to the GetKeyValuePairs method.
+		GetKeyValuePairs []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// MatchPattern is the matchPattern argument value.
+			MatchPattern string
+			// Count is the count argument value.
+			Count int64
+			// Cursor is the cursor argument value.
+			Cursor uint64
+		}
+		// GetList holds details about calls to the GetList method.
+		GetList []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
 		// GetValue holds details about calls to the GetValue method.
 		GetValue []struct {
 			// Ctx is the ctx argument value.
@@ -55,9 +141,67 @@ type RedisMock struct {
 			// Key is the key argument value.
 			Key string
 		}
+		// RenameNX holds details about calls to the RenameNX method.
+		RenameNX []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// NewKey is the newKey argument value.
+			NewKey string
+		}
+		// RunScript holds details about calls to the RunScript method.
+		RunScript []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Script is the script argument value.
+			Script *redis.Script
+			// Keys is the keys argument value.
+			Keys []string
+			// Args is the args argument value.
+			Args []interface{}
+		}
+		// ScanKeys holds details about calls to the ScanKeys method.
+		ScanKeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// MatchPattern is the matchPattern argument value.
+			MatchPattern string
+			// Count is the count argument value.
+			Count int64
+			// Fn is the fn argument value.
+			Fn func(keys []string) error
+		}
+		// SetValue holds details about calls to the SetValue method.
+		SetValue []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Value is the value argument value.
+			Value interface{}
+			// Expiration is the expiration argument value.
+			Expiration time.Duration
+		}
+		// Transaction holds details about calls to the Transaction method.
+		Transaction []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Commands is the commands argument value.
+			Commands []clients.Command
+		}
 	}
-	lockChecker  sync.RWMutex
-	lockGetValue sync.RWMutex
+	lockChecker          sync.RWMutex
+	lockClose            sync.RWMutex
+	lockDeleteValue      sync.RWMutex
+	lockGetKeyValuePairs sync.RWMutex
+	lockGetList          sync.RWMutex
+	lockGetValue         sync.RWMutex
+	lockRenameNX         sync.RWMutex
+	lockRunScript        sync.RWMutex
+	lockScanKeys         sync.RWMutex
+	lockSetValue         sync.RWMutex
+	lockTransaction      sync.RWMutex
 }
 
 // Checker calls CheckerFunc.
@@ -96,6 +240,154 @@ func (mock *RedisMock) CheckerCalls() []struct {
 	return calls
 }
 
+// Close calls CloseFunc.
+func (mock *RedisMock) Close(ctx context.Context) error {
+	if mock.CloseFunc == nil {
+		panic("RedisMock.CloseFunc: method is nil but Redis.Close was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockClose.Lock()
+	mock.calls.Close = append(mock.calls.Close, callInfo)
+	mock.lockClose.Unlock()
+	return mock.CloseFunc(ctx)
+}
+
+// CloseCalls gets all the calls that were made to Close.
+// Check the length with:
+//
+//	len(mockedRedis.CloseCalls())
+func (mock *RedisMock) CloseCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockClose.RLock()
+	calls = mock.calls.Close
+	mock.lockClose.RUnlock()
+	return calls
+}
+
+// DeleteValue calls DeleteValueFunc.
+func (mock *RedisMock) DeleteValue(ctx context.Context, key string) error {
+	if mock.DeleteValueFunc == nil {
+		panic("RedisMock.DeleteValueFunc: method is nil but Redis.DeleteValue was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockDeleteValue.Lock()
+	mock.calls.DeleteValue = append(mock.calls.DeleteValue, callInfo)
+	mock.lockDeleteValue.Unlock()
+	return mock.DeleteValueFunc(ctx, key)
+}
+
+// DeleteValueCalls gets all the calls that were made to DeleteValue.
+// Check the length with:
+//
+//	len(mockedRedis.DeleteValueCalls())
+func (mock *RedisMock) DeleteValueCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockDeleteValue.RLock()
+	calls = mock.calls.DeleteValue
+	mock.lockDeleteValue.RUnlock()
+	return calls
+}
+
+// GetKeyValuePairs calls GetKeyValuePairsFunc.
+func (mock *RedisMock) GetKeyValuePairs(ctx context.Context, matchPattern string, count int64, cursor uint64) (map[string]string, uint64, error) {
+	if mock.GetKeyValuePairsFunc == nil {
+		panic("RedisMock.GetKeyValuePairsFunc: method is nil but Redis.GetKeyValuePairs was just called")
+	}
+	callInfo := struct {
+		Ctx          context.Context
+		MatchPattern string
+		Count        int64
+		Cursor       uint64
+	}{
+		Ctx:          ctx,
+		MatchPattern: matchPattern,
+		Count:        count,
+		Cursor:       cursor,
+	}
+	mock.lockGetKeyValuePairs.Lock()
+	mock.calls.GetKeyValuePairs = append(mock.calls.GetKeyValuePairs, callInfo)
+	mock.lockGetKeyValuePairs.Unlock()
+	return mock.GetKeyValuePairsFunc(ctx, matchPattern, count, cursor)
+}
+
+// GetKeyValuePairsCalls gets all the calls that were made to GetKeyValuePairs.
+// Check the length with:
+//
+//	len(mockedRedis.GetKeyValuePairsCalls())
+func (mock *RedisMock) GetKeyValuePairsCalls() []struct {
+	Ctx          context.Context
+	MatchPattern string
+	Count        int64
+	Cursor       uint64
+} {
+	var calls []struct {
+		Ctx          context.Context
+		MatchPattern string
+		Count        int64
+		Cursor       uint64
+	}
+	mock.lockGetKeyValuePairs.RLock()
+	calls = mock.calls.GetKeyValuePairs
+	mock.lockGetKeyValuePairs.RUnlock()
+	return calls
+}
+
+// GetList calls GetListFunc.
+func (mock *RedisMock) GetList(ctx context.Context, key string) ([]string, error) {
+	if mock.GetListFunc == nil {
+		panic("RedisMock.GetListFunc: method is nil but Redis.GetList was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockGetList.Lock()
+	mock.calls.GetList = append(mock.calls.GetList, callInfo)
+	mock.lockGetList.Unlock()
+	return mock.GetListFunc(ctx, key)
+}
+
+// GetListCalls gets all the calls that were made to GetList.
+// Check the length with:
+//
+//	len(mockedRedis.GetListCalls())
+func (mock *RedisMock) GetListCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockGetList.RLock()
+	calls = mock.calls.GetList
+	mock.lockGetList.RUnlock()
+	return calls
+}
+
 // GetValue calls GetValueFunc.
 func (mock *RedisMock) GetValue(ctx context.Context, key string) (string, error) {
 	if mock.GetValueFunc == nil {
@@ -131,3 +423,211 @@ func (mock *RedisMock) GetValueCalls() []struct {
 	mock.lockGetValue.RUnlock()
 	return calls
 }
+
+// RenameNX calls RenameNXFunc.
+func (mock *RedisMock) RenameNX(ctx context.Context, key string, newKey string) (bool, error) {
+	if mock.RenameNXFunc == nil {
+		panic("RedisMock.RenameNXFunc: method is nil but Redis.RenameNX was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Key    string
+		NewKey string
+	}{
+		Ctx:    ctx,
+		Key:    key,
+		NewKey: newKey,
+	}
+	mock.lockRenameNX.Lock()
+	mock.calls.RenameNX = append(mock.calls.RenameNX, callInfo)
+	mock.lockRenameNX.Unlock()
+	return mock.RenameNXFunc(ctx, key, newKey)
+}
+
+// RenameNXCalls gets all the calls that were made to RenameNX.
+// Check the length with:
+//
+//	len(mockedRedis.RenameNXCalls())
+func (mock *RedisMock) RenameNXCalls() []struct {
+	Ctx    context.Context
+	Key    string
+	NewKey string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Key    string
+		NewKey string
+	}
+	mock.lockRenameNX.RLock()
+	calls = mock.calls.RenameNX
+	mock.lockRenameNX.RUnlock()
+	return calls
+}
+
+// RunScript calls RunScriptFunc.
+func (mock *RedisMock) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	if mock.RunScriptFunc == nil {
+		panic("RedisMock.RunScriptFunc: method is nil but Redis.RunScript was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Script *redis.Script
+		Keys   []string
+		Args   []interface{}
+	}{
+		Ctx:    ctx,
+		Script: script,
+		Keys:   keys,
+		Args:   args,
+	}
+	mock.lockRunScript.Lock()
+	mock.calls.RunScript = append(mock.calls.RunScript, callInfo)
+	mock.lockRunScript.Unlock()
+	return mock.RunScriptFunc(ctx, script, keys, args...)
+}
+
+// RunScriptCalls gets all the calls that were made to RunScript.
+// Check the length with:
+//
+//	len(mockedRedis.RunScriptCalls())
+func (mock *RedisMock) RunScriptCalls() []struct {
+	Ctx    context.Context
+	Script *redis.Script
+	Keys   []string
+	Args   []interface{}
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Script *redis.Script
+		Keys   []string
+		Args   []interface{}
+	}
+	mock.lockRunScript.RLock()
+	calls = mock.calls.RunScript
+	mock.lockRunScript.RUnlock()
+	return calls
+}
+
+// ScanKeys calls ScanKeysFunc.
+func (mock *RedisMock) ScanKeys(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error {
+	if mock.ScanKeysFunc == nil {
+		panic("RedisMock.ScanKeysFunc: method is nil but Redis.ScanKeys was just called")
+	}
+	callInfo := struct {
+		Ctx          context.Context
+		MatchPattern string
+		Count        int64
+		Fn           func(keys []string) error
+	}{
+		Ctx:          ctx,
+		MatchPattern: matchPattern,
+		Count:        count,
+		Fn:           fn,
+	}
+	mock.lockScanKeys.Lock()
+	mock.calls.ScanKeys = append(mock.calls.ScanKeys, callInfo)
+	mock.lockScanKeys.Unlock()
+	return mock.ScanKeysFunc(ctx, matchPattern, count, fn)
+}
+
+// ScanKeysCalls gets all the calls that were made to ScanKeys.
+// Check the length with:
+//
+//	len(mockedRedis.ScanKeysCalls())
+func (mock *RedisMock) ScanKeysCalls() []struct {
+	Ctx          context.Context
+	MatchPattern string
+	Count        int64
+	Fn           func(keys []string) error
+} {
+	var calls []struct {
+		Ctx          context.Context
+		MatchPattern string
+		Count        int64
+		Fn           func(keys []string) error
+	}
+	mock.lockScanKeys.RLock()
+	calls = mock.calls.ScanKeys
+	mock.lockScanKeys.RUnlock()
+	return calls
+}
+
+// SetValue calls SetValueFunc.
+func (mock *RedisMock) SetValue(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if mock.SetValueFunc == nil {
+		panic("RedisMock.SetValueFunc: method is nil but Redis.SetValue was just called")
+	}
+	callInfo := struct {
+		Ctx        context.Context
+		Key        string
+		Value      interface{}
+		Expiration time.Duration
+	}{
+		Ctx:        ctx,
+		Key:        key,
+		Value:      value,
+		Expiration: expiration,
+	}
+	mock.lockSetValue.Lock()
+	mock.calls.SetValue = append(mock.calls.SetValue, callInfo)
+	mock.lockSetValue.Unlock()
+	return mock.SetValueFunc(ctx, key, value, expiration)
+}
+
+// SetValueCalls gets all the calls that were made to SetValue.
+// Check the length with:
+//
+//	len(mockedRedis.SetValueCalls())
+func (mock *RedisMock) SetValueCalls() []struct {
+	Ctx        context.Context
+	Key        string
+	Value      interface{}
+	Expiration time.Duration
+} {
+	var calls []struct {
+		Ctx        context.Context
+		Key        string
+		Value      interface{}
+		Expiration time.Duration
+	}
+	mock.lockSetValue.RLock()
+	calls = mock.calls.SetValue
+	mock.lockSetValue.RUnlock()
+	return calls
+}
+
+// Transaction calls TransactionFunc.
+func (mock *RedisMock) Transaction(ctx context.Context, commands ...clients.Command) error {
+	if mock.TransactionFunc == nil {
+		panic("RedisMock.TransactionFunc: method is nil but Redis.Transaction was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Commands []clients.Command
+	}{
+		Ctx:      ctx,
+		Commands: commands,
+	}
+	mock.lockTransaction.Lock()
+	mock.calls.Transaction = append(mock.calls.Transaction, callInfo)
+	mock.lockTransaction.Unlock()
+	return mock.TransactionFunc(ctx, commands...)
+}
+
+// TransactionCalls gets all the calls that were made to Transaction.
+// Check the length with:
+//
+//	len(mockedRedis.TransactionCalls())
+func (mock *RedisMock) TransactionCalls() []struct {
+	Ctx      context.Context
+	Commands []clients.Command
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Commands []clients.Command
+	}
+	mock.lockTransaction.RLock()
+	calls = mock.calls.Transaction
+	mock.lockTransaction.RUnlock()
+	return calls
+}
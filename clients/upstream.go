@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+// maxCheckBodySize is the most of an upstream's health check response that is read, so that the connection can
+// be reused
+const maxCheckBodySize = 64 * 1024
+
+// Upstream checks the health of a site that requests are proxied to
+type Upstream struct {
+	name           string
+	url            string
+	expectedStatus int
+	severity       string
+	client         *http.Client
+}
+
+// NewUpstream creates an Upstream that requests path from the site at baseURL. The upstream is unhealthy, with the
+// given severity, if it can't be reached within timeout or doesn't respond with expectedStatus. Redirects are not
+// followed, so that the status of the path itself is checked.
+func NewUpstream(name, baseURL, path string, expectedStatus int, severity string, timeout time.Duration) *Upstream {
+	return &Upstream{
+		name:           name,
+		url:            strings.TrimSuffix(baseURL, "/") + path,
+		expectedStatus: expectedStatus,
+		severity:       severity,
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Checker implements healthcheck.Checker
+func (u *Upstream) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url, http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return state.Update(u.severity, fmt.Sprintf("%s is unreachable: %s", u.name, err), 0)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxCheckBodySize))
+
+	if resp.StatusCode != u.expectedStatus {
+		message := fmt.Sprintf("%s returned status %d, expected %d", u.name, resp.StatusCode, u.expectedStatus)
+		return state.Update(u.severity, message, resp.StatusCode)
+	}
+	return state.Update(healthcheck.StatusOK, u.name+" is healthy", resp.StatusCode)
+}
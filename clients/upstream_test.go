@@ -0,0 +1,62 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUpstreamChecker(t *testing.T) {
+	Convey("Given an upstream site", t, func() {
+		status := http.StatusOK
+		var requestedPath string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestedPath = r.URL.Path
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		upstream := NewUpstream("legacy site", server.URL+"/", "/health", http.StatusOK, healthcheck.StatusCritical, time.Second)
+		state := healthcheck.NewCheckState("Legacy site")
+
+		Convey("When it responds with the expected status", func() {
+			err := upstream.Checker(context.Background(), state)
+
+			Convey("Then it is healthy", func() {
+				So(err, ShouldBeNil)
+				So(requestedPath, ShouldEqual, "/health")
+				So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+				So(state.Message(), ShouldEqual, "legacy site is healthy")
+			})
+		})
+
+		Convey("When it responds with another status", func() {
+			status = http.StatusFound
+			err := upstream.Checker(context.Background(), state)
+
+			Convey("Then it has the configured severity, without the redirect being followed", func() {
+				So(err, ShouldBeNil)
+				So(state.Status(), ShouldEqual, healthcheck.StatusCritical)
+				So(state.StatusCode(), ShouldEqual, http.StatusFound)
+				So(state.Message(), ShouldEqual, "legacy site returned status 302, expected 200")
+			})
+		})
+
+		Convey("When it can't be reached", func() {
+			server.Close()
+			wagtail := NewUpstream("wagtail", server.URL, "/", http.StatusOK, healthcheck.StatusWarning, time.Second)
+			err := wagtail.Checker(context.Background(), state)
+
+			Convey("Then it has the configured severity", func() {
+				So(err, ShouldBeNil)
+				So(state.Status(), ShouldEqual, healthcheck.StatusWarning)
+				So(state.Message(), ShouldStartWith, "wagtail is unreachable")
+			})
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/redirects"
+	"github.com/ONSdigital/dis-redirect-proxy/service"
+	"github.com/pkg/errors"
+)
+
+// commands describes the commands that can be given on the command line instead of running the proxy
+const commands = `commands:
+  config print              print the effective config, merged from CONFIG_FILE and the environment, with secrets redacted
+  redirects list upcoming   list the redirects that aren't active yet because their not_before time hasn't passed
+  redirects list expired    list the redirects that are no longer active because their not_after time has passed
+  redirects migrate-keys    move the redirects and their histories stored under their bare path to keys under
+                            REDIRECT_KEY_PREFIX, skipping any that already exist under it; the maintenance,
+                            feature_flags, cache: and ratelimit: keys stay as they are`
+
+// runCommand runs the command given by args, writing its output to stdout
+func runCommand(args []string, stdout io.Writer) error {
+	switch strings.Join(args, " ") {
+	case "config print":
+		cfg, err := config.Get()
+		if err != nil {
+			return errors.Wrap(err, "error getting configuration")
+		}
+		return cfg.Redacted().WriteYAML(stdout)
+	case "redirects list upcoming":
+		return listRedirects(context.Background(), stdout, redirects.StatusUpcoming)
+	case "redirects list expired":
+		return listRedirects(context.Background(), stdout, redirects.StatusExpired)
+	case "redirects migrate-keys":
+		return migrateRedirectKeys(context.Background(), stdout)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", strings.Join(args, " "), commands)
+	}
+}
+
+// getRedisClient returns the config and a client for the Redis it configures, which must be closed by the caller
+func getRedisClient(ctx context.Context) (*config.Config, clients.Redis, error) {
+	cfg, err := config.Get()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error getting configuration")
+	}
+
+	redisCli, err := service.GetRedisClient(ctx, cfg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error creating redis client")
+	}
+	return cfg, redisCli, nil
+}
+
+// listRedirects writes a table of the redirects with the given status
+func listRedirects(ctx context.Context, stdout io.Writer, status string) error {
+	cfg, redisCli, err := getRedisClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = redisCli.Close(ctx) }()
+
+	list, err := redirects.List(ctx, redisCli, cfg.RedirectKeyPrefix, status, time.Now())
+	if err != nil {
+		return errors.Wrapf(err, "error listing %s redirects", status)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tTO\tNOT BEFORE\tNOT AFTER")
+	for _, redirect := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", redirect.Path, redirect.To, formatTime(redirect.NotBefore), formatTime(redirect.NotAfter))
+	}
+	return w.Flush()
+}
+
+// formatTime formats an optional time of a redirect's window, which is shown as - if it isn't set
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// migrateRedirectKeys moves the redirects stored under their bare path to keys under REDIRECT_KEY_PREFIX
+func migrateRedirectKeys(ctx context.Context, stdout io.Writer) error {
+	cfg, redisCli, err := getRedisClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = redisCli.Close(ctx) }()
+
+	migration, err := redirects.MigrateKeys(ctx, redisCli, cfg.RedirectKeyPrefix)
+	if err != nil {
+		return errors.Wrapf(err, "error migrating redirect keys, after migrating %d", migration.Migrated)
+	}
+	if _, err := fmt.Fprintf(stdout, "migrated %d redirect keys to prefix %q\n", migration.Migrated, cfg.RedirectKeyPrefix); err != nil {
+		return err
+	}
+	for _, key := range migration.Skipped {
+		if _, err := fmt.Fprintf(stdout, "skipped %s, as it already exists under the prefix\n", key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
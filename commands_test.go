@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/ONSdigital/dis-redirect-proxy/clients/mock"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/service"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunCommand(t *testing.T) {
+	Convey("Given Redis holds redirects under a key prefix and one without it", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		keyPrefix := cfg.RedirectKeyPrefix
+		cfg.RedirectKeyPrefix = "redirect:"
+		Reset(func() { cfg.RedirectKeyPrefix = keyPrefix })
+
+		now := time.Now().UTC()
+		values := map[string]string{
+			"redirect:/launch":   `{"to":"/launched","not_before":"` + now.Add(time.Hour).Format(time.RFC3339) + `"}`,
+			"redirect:/campaign": `{"to":"/campaigns/spring","not_after":"` + now.Add(-time.Hour).Format(time.RFC3339) + `"}`,
+			"redirect:/active":   "/elsewhere",
+			"/old":               "/new",
+		}
+		// matching is the keys matching a pattern, which in these cases only ever ends with *
+		matching := func(pattern string) []string {
+			var keys []string
+			for key := range values {
+				if strings.HasPrefix(key, strings.TrimSuffix(pattern, "*")) {
+					keys = append(keys, key)
+				}
+			}
+			return keys
+		}
+		redisMock := &mock.RedisMock{
+			GetValueFunc: func(ctx context.Context, key string) (string, error) {
+				return values[key], nil
+			},
+			ScanKeysFunc: func(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error {
+				return fn(matching(matchPattern))
+			},
+			RenameNXFunc: func(ctx context.Context, key, newKey string) (bool, error) {
+				if _, exists := values[newKey]; exists {
+					return false, nil
+				}
+				values[newKey] = values[key]
+				delete(values, key)
+				return true, nil
+			},
+			CloseFunc: func(ctx context.Context) error { return nil },
+		}
+		getRedisClient := service.GetRedisClient
+		service.GetRedisClient = func(ctx context.Context, cfg *config.Config) (clients.Redis, error) {
+			return redisMock, nil
+		}
+		Reset(func() { service.GetRedisClient = getRedisClient })
+
+		var stdout bytes.Buffer
+
+		Convey("When the upcoming redirects are listed", func() {
+			So(runCommand([]string{"redirects", "list", "upcoming"}, &stdout), ShouldBeNil)
+
+			Convey("Then only the redirect that isn't active yet is shown", func() {
+				lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+				So(lines, ShouldHaveLength, 2)
+				So(strings.Fields(lines[0]), ShouldResemble, []string{"PATH", "TO", "NOT", "BEFORE", "NOT", "AFTER"})
+				So(strings.Fields(lines[1]), ShouldResemble, []string{"/launch", "/launched", now.Add(time.Hour).Format(time.RFC3339), "-"})
+			})
+
+			Convey("And the Redis client is closed", func() {
+				So(redisMock.CloseCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the expired redirects are listed", func() {
+			So(runCommand([]string{"redirects", "list", "expired"}, &stdout), ShouldBeNil)
+
+			Convey("Then only the redirect that is no longer active is shown", func() {
+				lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+				So(lines, ShouldHaveLength, 2)
+				So(strings.Fields(lines[1]), ShouldResemble, []string{"/campaign", "/campaigns/spring", "-", now.Add(-time.Hour).Format(time.RFC3339)})
+			})
+		})
+
+		Convey("When the redirect keys are migrated", func() {
+			So(runCommand([]string{"redirects", "migrate-keys"}, &stdout), ShouldBeNil)
+
+			Convey("Then the redirect without the prefix is renamed under it", func() {
+				So(stdout.String(), ShouldEqual, "migrated 1 redirect keys to prefix \"redirect:\"\n")
+				So(values, ShouldNotContainKey, "/old")
+				So(values["redirect:/old"], ShouldEqual, "/new")
+			})
+		})
+
+		Convey("When a redirect has already been set under the prefix and the keys are migrated", func() {
+			values["redirect:/old"] = "/newer"
+			So(runCommand([]string{"redirects", "migrate-keys"}, &stdout), ShouldBeNil)
+
+			Convey("Then the redirect without the prefix is skipped and reported", func() {
+				So(stdout.String(), ShouldEqual, "migrated 0 redirect keys to prefix \"redirect:\"\n"+
+					"skipped /old, as it already exists under the prefix\n")
+				So(values["redirect:/old"], ShouldEqual, "/newer")
+				So(values["/old"], ShouldEqual, "/new")
+			})
+		})
+
+		Convey("When the redirect keys can't be renamed", func() {
+			redisMock.RenameNXFunc = func(ctx context.Context, key, newKey string) (bool, error) {
+				return false, errors.New("READONLY")
+			}
+			err := runCommand([]string{"redirects", "migrate-keys"}, &stdout)
+
+			Convey("Then the error is returned", func() {
+				So(err, ShouldBeError, "error migrating redirect keys, after migrating 0: READONLY")
+				So(stdout.String(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When Redis can't be connected to", func() {
+			service.GetRedisClient = func(ctx context.Context, cfg *config.Config) (clients.Redis, error) {
+				return nil, errors.New("connection refused")
+			}
+			err := runCommand([]string{"redirects", "list", "upcoming"}, &stdout)
+
+			Convey("Then the error is returned", func() {
+				So(err, ShouldBeError, "error creating redis client: connection refused")
+			})
+		})
+
+		Convey("When the command is unknown", func() {
+			err := runCommand([]string{"redirects", "list", "active"}, &stdout)
+
+			Convey("Then the error lists the commands", func() {
+				So(err.Error(), ShouldStartWith, "unknown command \"redirects list active\"\ncommands:\n")
+			})
+		})
+	})
+}
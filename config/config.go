@@ -1,36 +1,224 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"os"
 	"time"
 
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	"github.com/kelseyhightower/envconfig"
 )
 
 const (
 	RedisTLSProtocol = "TLS"
+
+	RedisReadRoutingPrimary = "primary"
+	RedisReadRoutingReplica = "replica"
+	RedisReadRoutingRandom  = "random"
+	RedisReadRoutingLatency = "latency"
+
+	CacheStoreMemory = "memory"
+	CacheStoreRedis  = "redis"
+
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+
+	FeatureFlagsSourceFile  = "file"
+	FeatureFlagsSourceRedis = "redis"
+
+	RetryErrorConnectionReset   = "connection_reset"
+	RetryErrorConnectionRefused = "connection_refused"
+	RetryErrorBrokenPipe        = "broken_pipe"
+	RetryErrorConnectionClosed  = "connection_closed"
 )
 
-// Config represents service configuration for dis-redirect-proxy
+// Config represents service configuration for dis-redirect-proxy. Fields tagged `secret:"true"` are redacted
+// whenever the config is logged, printed or encoded as JSON.
 type Config struct {
-	BindAddr                   string        `envconfig:"BIND_ADDR"`
-	EnableRedirects            bool          `envconfig:"ENABLE_REDIRECTS"`
-	EnableReleasesFallback     bool          `envconfig:"ENABLE_RELEASES_FALLBACK"`
-	GracefulShutdownTimeout    time.Duration `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
-	HealthCheckInterval        time.Duration `envconfig:"HEALTHCHECK_INTERVAL"`
-	HealthCheckCriticalTimeout time.Duration `envconfig:"HEALTHCHECK_CRITICAL_TIMEOUT"`
-	ProxiedServiceURL          string        `envconfig:"PROXIED_SERVICE_URL"`
-	OTBatchTimeout             time.Duration `encconfig:"OTEL_BATCH_TIMEOUT"`
-	OTExporterOTLPEndpoint     string        `envconfig:"OTEL_EXPORTER_OTLP_ENDPOINT"`
-	OTServiceName              string        `envconfig:"OTEL_SERVICE_NAME"`
-	OtelEnabled                bool          `envconfig:"OTEL_ENABLED"`
-	RedisAddress               string        `envconfig:"REDIS_ADDRESS"`
-	RedisClusterName           string        `envconfig:"REDIS_CLUSTER_NAME"`
-	RedisRegion                string        `envconfig:"REDIS_REGION"`
-	RedisSecProtocol           string        `envconfig:"REDIS_SEC_PROTO"`
-	RedisService               string        `envconfig:"REDIS_SERVICE"`
-	RedisUsername              string        `envconfig:"REDIS_USERNAME"`
-	WagtailURL                 string        `envconfig:"WAGTAIL_URL"` // TODO consider naming
+	AdminAPIKey                string         `envconfig:"ADMIN_API_KEY" secret:"true"`
+	BindAddr                   string         `envconfig:"BIND_ADDR"`
+	CacheAuthCookies           []string       `envconfig:"CACHE_AUTH_COOKIES"`
+	CacheEnabled               bool           `envconfig:"CACHE_ENABLED"`
+	CacheMaxBodySize           int64          `envconfig:"CACHE_MAX_BODY_SIZE"`
+	CacheMaxBytes              int64          `envconfig:"CACHE_MAX_BYTES"`
+	CacheMaxEntries            int            `envconfig:"CACHE_MAX_ENTRIES"`
+	CacheRules                 CacheRules     `envconfig:"CACHE_RULES"`
+	CacheStore                 string         `envconfig:"CACHE_STORE"`
+	CompressionContentTypes    []string       `envconfig:"COMPRESSION_CONTENT_TYPES"`
+	CompressionEnabled         bool           `envconfig:"COMPRESSION_ENABLED"`
+	CompressionMinSize         int            `envconfig:"COMPRESSION_MIN_SIZE"`
+	EnableRedirects            bool           `envconfig:"ENABLE_REDIRECTS"`
+	EnableReleasesFallback     bool           `envconfig:"ENABLE_RELEASES_FALLBACK"`
+	ErrorPageTemplatePath      string         `envconfig:"ERROR_PAGE_TEMPLATE_PATH"`
+	FeatureFlagsFile           string         `envconfig:"FEATURE_FLAGS_FILE"`
+	FeatureFlagsPollInterval   time.Duration  `envconfig:"FEATURE_FLAGS_POLL_INTERVAL"`
+	FeatureFlagsSource         string         `envconfig:"FEATURE_FLAGS_SOURCE"`
+	GracefulShutdownDrain      time.Duration  `envconfig:"GRACEFUL_SHUTDOWN_DRAIN"`
+	GracefulShutdownTimeout    time.Duration  `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
+	HealthCheckInterval        time.Duration  `envconfig:"HEALTHCHECK_INTERVAL"`
+	HealthCheckCriticalTimeout time.Duration  `envconfig:"HEALTHCHECK_CRITICAL_TIMEOUT"`
+	HealthCheckLegacyPath      string         `envconfig:"HEALTHCHECK_LEGACY_PATH"`
+	HealthCheckLegacySeverity  string         `envconfig:"HEALTHCHECK_LEGACY_SEVERITY"`
+	HealthCheckLegacyStatus    int            `envconfig:"HEALTHCHECK_LEGACY_STATUS"`
+	HealthCheckUpstreamTimeout time.Duration  `envconfig:"HEALTHCHECK_UPSTREAM_TIMEOUT"`
+	HealthCheckUpstreams       bool           `envconfig:"HEALTHCHECK_UPSTREAMS"`
+	HealthCheckWagtailPath     string         `envconfig:"HEALTHCHECK_WAGTAIL_PATH"`
+	HealthCheckWagtailSeverity string         `envconfig:"HEALTHCHECK_WAGTAIL_SEVERITY"`
+	HealthCheckWagtailStatus   int            `envconfig:"HEALTHCHECK_WAGTAIL_STATUS"`
+	ProxiedServiceURL          string         `envconfig:"PROXIED_SERVICE_URL"`
+	ProxyCoalescingAuthCookies []string       `envconfig:"PROXY_COALESCING_AUTH_COOKIES"`
+	ProxyCoalescingEnabled     bool           `envconfig:"PROXY_COALESCING_ENABLED"`
+	ProxyCoalescingMaxBodySize int64          `envconfig:"PROXY_COALESCING_MAX_BODY_SIZE"`
+	ProxyRetryBudgetMinPerSec  int            `envconfig:"PROXY_RETRY_BUDGET_MIN_PER_SEC"`
+	ProxyRetryBudgetRatio      float64        `envconfig:"PROXY_RETRY_BUDGET_RATIO"`
+	ProxyRetryErrors           []string       `envconfig:"PROXY_RETRY_ERRORS"`
+	ProxyRetryInitialBackoff   time.Duration  `envconfig:"PROXY_RETRY_INITIAL_BACKOFF"`
+	ProxyRetryMaxAttempts      int            `envconfig:"PROXY_RETRY_MAX_ATTEMPTS"`
+	ProxyRetryMaxBackoff       time.Duration  `envconfig:"PROXY_RETRY_MAX_BACKOFF"`
+	ProxyRetryStatusCodes      []int          `envconfig:"PROXY_RETRY_STATUS_CODES"`
+	MaintenanceAllowedIPs      []string       `envconfig:"MAINTENANCE_ALLOWED_IPS"`
+	MaintenanceBypassToken     string         `envconfig:"MAINTENANCE_BYPASS_TOKEN" secret:"true"`
+	MaintenanceEnabled         bool           `envconfig:"MAINTENANCE_ENABLED"`
+	MaintenanceTemplatePath    string         `envconfig:"MAINTENANCE_PAGE_TEMPLATE_PATH"`
+	MaintenancePollInterval    time.Duration  `envconfig:"MAINTENANCE_POLL_INTERVAL"`
+	MaintenanceRetryAfter      time.Duration  `envconfig:"MAINTENANCE_RETRY_AFTER"`
+	OTBatchTimeout             time.Duration  `envconfig:"OTEL_BATCH_TIMEOUT"`
+	OTExporterOTLPEndpoint     string         `envconfig:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTServiceName              string         `envconfig:"OTEL_SERVICE_NAME"`
+	OtelEnabled                bool           `envconfig:"OTEL_ENABLED"`
+	RateLimitBurst             int            `envconfig:"RATE_LIMIT_BURST"`
+	RateLimitEnabled           bool           `envconfig:"RATE_LIMIT_ENABLED"`
+	RateLimitRequestsPerSecond float64        `envconfig:"RATE_LIMIT_REQUESTS_PER_SECOND"`
+	RateLimitRules             RateLimitRules `envconfig:"RATE_LIMIT_RULES"`
+	RateLimitStore             string         `envconfig:"RATE_LIMIT_STORE"`
+	RedirectKeyPrefix          string         `envconfig:"REDIRECT_KEY_PREFIX"`
+	RedisAddress               string         `envconfig:"REDIS_ADDRESS"`
+	RedisClusterName           string         `envconfig:"REDIS_CLUSTER_NAME"`
+	RedisConnectBackoff        time.Duration  `envconfig:"REDIS_CONNECT_BACKOFF"`
+	RedisConnectMaxBackoff     time.Duration  `envconfig:"REDIS_CONNECT_MAX_BACKOFF"`
+	RedisMinIdleConns          int            `envconfig:"REDIS_MIN_IDLE_CONNS"`
+	RedisPassword              string         `envconfig:"REDIS_PASSWORD" secret:"true"`
+	RedisPasswordFile          string         `envconfig:"REDIS_PASSWORD_FILE"`
+	RedisPoolSize              int            `envconfig:"REDIS_POOL_SIZE"`
+	RedisReadRouting           string         `envconfig:"REDIS_READ_ROUTING"`
+	RedisReadTimeout           time.Duration  `envconfig:"REDIS_READ_TIMEOUT"`
+	RedisRegion                string         `envconfig:"REDIS_REGION"`
+	RedisSecProtocol           string         `envconfig:"REDIS_SEC_PROTO"`
+	RedisService               string         `envconfig:"REDIS_SERVICE"`
+	RedisTLSCAFile             string         `envconfig:"REDIS_TLS_CA_FILE"`
+	RedisTLSCertFile           string         `envconfig:"REDIS_TLS_CERT_FILE"`
+	RedisTLSKeyFile            string         `envconfig:"REDIS_TLS_KEY_FILE"`
+	RedisUsername              string         `envconfig:"REDIS_USERNAME"`
+	RedisWriteTimeout          time.Duration  `envconfig:"REDIS_WRITE_TIMEOUT"`
+	ResponseHeaderPolicies     HeaderPolicies `envconfig:"RESPONSE_HEADER_POLICIES"`
+	TrustedProxyHops           int            `envconfig:"TRUSTED_PROXY_HOPS"`
+	UpstreamHeaderRules        HeaderRules    `envconfig:"UPSTREAM_HEADER_RULES" secret:"true"`
+	WagtailURL                 string         `envconfig:"WAGTAIL_URL"` // TODO consider naming
+}
+
+// HeaderPolicy describes how response headers are modified for requests whose path starts with PathPrefix
+type HeaderPolicy struct {
+	PathPrefix string            `json:"path_prefix"`
+	Set        map[string]string `json:"set,omitempty"`
+	Default    map[string]string `json:"default,omitempty"`
+	Remove     []string          `json:"remove,omitempty"`
+}
+
+// HeaderPolicies is an ordered list of header policies, provided as a JSON array
+type HeaderPolicies []HeaderPolicy
+
+// Decode implements envconfig.Decoder
+func (p *HeaderPolicies) Decode(value string) error {
+	return json.Unmarshal([]byte(value), p)
+}
+
+// HeaderRule describes how request headers are modified before a request is forwarded to an upstream. The
+// values of added and set headers may be credentials, such as an auth token, so they are redacted.
+type HeaderRule struct {
+	Remove            []string          `json:"remove,omitempty"`
+	Rename            map[string]string `json:"rename,omitempty"`
+	GenerateIfMissing []string          `json:"generate_if_missing,omitempty"`
+	Add               map[string]string `json:"add,omitempty" secret:"true"`
+	Set               map[string]string `json:"set,omitempty" secret:"true"`
+}
+
+// HeaderRules maps an upstream name ("legacy" or "wagtail") to the rule for requests forwarded to it,
+// provided as a JSON object
+type HeaderRules map[string]HeaderRule
+
+// Decode implements envconfig.Decoder
+func (r *HeaderRules) Decode(value string) error {
+	return json.Unmarshal([]byte(value), r)
+}
+
+// CacheRule overrides the caching behaviour for requests whose path starts with PathPrefix. TTL is only used
+// when the upstream response has no freshness information of its own, and the stale durations are only
+// used when the upstream response doesn't set the equivalent Cache-Control extensions.
+type CacheRule struct {
+	PathPrefix           string   `json:"path_prefix"`
+	Disabled             bool     `json:"disabled,omitempty"`
+	TTL                  Duration `json:"ttl,omitempty"`
+	StaleWhileRevalidate Duration `json:"stale_while_revalidate,omitempty"`
+	StaleIfError         Duration `json:"stale_if_error,omitempty"`
+}
+
+// CacheRules is a list of cache rules, provided as a JSON array. The rule with the longest matching
+// path prefix applies.
+type CacheRules []CacheRule
+
+// Decode implements envconfig.Decoder
+func (r *CacheRules) Decode(value string) error {
+	return json.Unmarshal([]byte(value), r)
+}
+
+// RateLimitRule adds a separate limit for requests whose path starts with PathPrefix, which applies in
+// addition to the limit for all requests from the client
+type RateLimitRule struct {
+	PathPrefix        string  `json:"path_prefix"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// RateLimitRules is a list of per route rate limits, provided as a JSON array. The rule with the longest
+// matching path prefix applies.
+type RateLimitRules []RateLimitRule
+
+// Decode implements envconfig.Decoder
+func (r *RateLimitRules) Decode(value string) error {
+	return json.Unmarshal([]byte(value), r)
+}
+
+// Duration is a time.Duration that is written in JSON as a duration string, e.g. "5m"
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5m\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// RedisRequired returns whether any of the enabled features use Redis, so that a Redis client is needed. Redirects
+// may be enabled at runtime if the feature flags have a dynamic source, and feature flags may be read from Redis.
+func (config *Config) RedisRequired() bool {
+	return config.EnableRedirects || config.FeatureFlagsSource != "" ||
+		(config.CacheEnabled && config.CacheStore == CacheStoreRedis) ||
+		(config.RateLimitEnabled && config.RateLimitStore == RateLimitStoreRedis) ||
+		config.MaintenanceEnabled
 }
 
 var cfg *Config
@@ -42,33 +230,112 @@ func Get() (*Config, error) {
 		return cfg, nil
 	}
 
-	cfg = &Config{
+	c, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	cfg = c
+	return cfg, nil
+}
+
+// Load reads the config afresh, without using or replacing the config returned by Get. It is used to reload
+// the config while the service is running.
+func Load() (*Config, error) {
+	cfg := &Config{
+		AdminAPIKey:                "",
 		BindAddr:                   "localhost:30000",
+		CacheEnabled:               false,
+		CacheAuthCookies:           []string{"access_token", "florence-id"},
+		CacheMaxBodySize:           2 * 1024 * 1024,
+		CacheMaxBytes:              256 * 1024 * 1024,
+		CacheMaxEntries:            10000,
+		CacheRules:                 CacheRules{},
+		CacheStore:                 CacheStoreMemory,
+		CompressionContentTypes:    []string{"application/javascript", "application/json", "application/xml", "image/svg+xml", "text/css", "text/csv", "text/html", "text/javascript", "text/plain", "text/xml"},
+		CompressionEnabled:         false,
+		CompressionMinSize:         1024,
 		EnableRedirects:            false,
 		EnableReleasesFallback:     false,
+		ErrorPageTemplatePath:      "",
+		FeatureFlagsFile:           "",
+		FeatureFlagsPollInterval:   10 * time.Second,
+		FeatureFlagsSource:         "",
+		GracefulShutdownDrain:      5 * time.Second,
 		GracefulShutdownTimeout:    5 * time.Second,
 		HealthCheckInterval:        30 * time.Second,
 		HealthCheckCriticalTimeout: 90 * time.Second,
+		HealthCheckLegacyPath:      "/",
+		HealthCheckLegacySeverity:  healthcheck.StatusCritical,
+		HealthCheckLegacyStatus:    http.StatusOK,
+		HealthCheckUpstreamTimeout: 5 * time.Second,
+		HealthCheckUpstreams:       false,
+		HealthCheckWagtailPath:     "/",
+		HealthCheckWagtailSeverity: healthcheck.StatusWarning,
+		HealthCheckWagtailStatus:   http.StatusOK,
 		ProxiedServiceURL:          "http://localhost:20000",
+		ProxyCoalescingAuthCookies: []string{"access_token", "florence-id"},
+		ProxyCoalescingEnabled:     false,
+		ProxyCoalescingMaxBodySize: 2 * 1024 * 1024,
+		ProxyRetryBudgetMinPerSec:  10,
+		ProxyRetryBudgetRatio:      0.2,
+		ProxyRetryErrors:           []string{RetryErrorConnectionReset, RetryErrorConnectionRefused, RetryErrorBrokenPipe, RetryErrorConnectionClosed},
+		ProxyRetryInitialBackoff:   50 * time.Millisecond,
+		ProxyRetryMaxAttempts:      3,
+		ProxyRetryMaxBackoff:       1 * time.Second,
+		ProxyRetryStatusCodes:      []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		MaintenanceAllowedIPs:      []string{},
+		MaintenanceBypassToken:     "",
+		MaintenanceEnabled:         false,
+		MaintenanceTemplatePath:    "",
+		MaintenancePollInterval:    5 * time.Second,
+		MaintenanceRetryAfter:      5 * time.Minute,
 		OTBatchTimeout:             5 * time.Second,
 		OTExporterOTLPEndpoint:     "localhost:4317",
 		OTServiceName:              "dis-redirect-proxy",
 		OtelEnabled:                false,
+		RateLimitBurst:             40,
+		RateLimitEnabled:           false,
+		RateLimitRequestsPerSecond: 20,
+		RateLimitRules:             RateLimitRules{},
+		RateLimitStore:             RateLimitStoreMemory,
+		RedirectKeyPrefix:          "",
 		RedisAddress:               "localhost:6379",
 		RedisClusterName:           "",
+		RedisConnectBackoff:        time.Second,
+		RedisConnectMaxBackoff:     time.Minute,
+		RedisMinIdleConns:          0,
+		RedisPassword:              "",
+		RedisPasswordFile:          "",
+		RedisPoolSize:              0,
+		RedisReadRouting:           RedisReadRoutingPrimary,
+		RedisReadTimeout:           3 * time.Second,
 		RedisRegion:                "",
 		RedisSecProtocol:           "",
 		RedisService:               "",
+		RedisTLSCAFile:             "",
+		RedisTLSCertFile:           "",
+		RedisTLSKeyFile:            "",
 		RedisUsername:              "",
+		RedisWriteTimeout:          3 * time.Second,
+		ResponseHeaderPolicies:     HeaderPolicies{},
+		TrustedProxyHops:           0,
+		UpstreamHeaderRules:        HeaderRules{},
 		WagtailURL:                 "http://localhost:8000",
 	}
 
+	// environment variables override values from the config file
+	if path := os.Getenv(FileEnvVar); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := envconfig.Process("", cfg); err != nil {
 		return nil, err
 	}
 
-	if cfg.ProxiedServiceURL == "" {
-		return nil, fmt.Errorf("missing required config: PROXIED_SERVICE_URL")
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
 	return cfg, nil
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -23,23 +24,84 @@ func TestConfig(t *testing.T) {
 				configuration, err = Get() // This Get() is only called once, when inside this function
 				So(err, ShouldBeNil)
 				So(configuration, ShouldResemble, &Config{
+					AdminAPIKey:                "",
 					BindAddr:                   "localhost:30000",
+					CacheEnabled:               false,
+					CacheAuthCookies:           []string{"access_token", "florence-id"},
+					CacheMaxBodySize:           2 * 1024 * 1024,
+					CacheMaxBytes:              256 * 1024 * 1024,
+					CacheMaxEntries:            10000,
+					CacheRules:                 CacheRules{},
+					CacheStore:                 CacheStoreMemory,
+					CompressionContentTypes:    []string{"application/javascript", "application/json", "application/xml", "image/svg+xml", "text/css", "text/csv", "text/html", "text/javascript", "text/plain", "text/xml"},
+					CompressionEnabled:         false,
+					CompressionMinSize:         1024,
 					EnableRedirects:            false,
 					EnableReleasesFallback:     false,
+					ErrorPageTemplatePath:      "",
+					FeatureFlagsFile:           "",
+					FeatureFlagsPollInterval:   10 * time.Second,
+					FeatureFlagsSource:         "",
+					GracefulShutdownDrain:      5 * time.Second,
 					GracefulShutdownTimeout:    5 * time.Second,
 					HealthCheckInterval:        30 * time.Second,
 					HealthCheckCriticalTimeout: 90 * time.Second,
+					HealthCheckLegacyPath:      "/",
+					HealthCheckLegacySeverity:  healthcheck.StatusCritical,
+					HealthCheckLegacyStatus:    200,
+					HealthCheckUpstreamTimeout: 5 * time.Second,
+					HealthCheckUpstreams:       false,
+					HealthCheckWagtailPath:     "/",
+					HealthCheckWagtailSeverity: healthcheck.StatusWarning,
+					HealthCheckWagtailStatus:   200,
 					ProxiedServiceURL:          "http://localhost:20000",
+					ProxyCoalescingAuthCookies: []string{"access_token", "florence-id"},
+					ProxyCoalescingEnabled:     false,
+					ProxyCoalescingMaxBodySize: 2 * 1024 * 1024,
+					ProxyRetryBudgetMinPerSec:  10,
+					ProxyRetryBudgetRatio:      0.2,
+					ProxyRetryErrors:           []string{"connection_reset", "connection_refused", "broken_pipe", "connection_closed"},
+					ProxyRetryInitialBackoff:   50 * time.Millisecond,
+					ProxyRetryMaxAttempts:      3,
+					ProxyRetryMaxBackoff:       1 * time.Second,
+					ProxyRetryStatusCodes:      []int{502, 503, 504},
+					MaintenanceAllowedIPs:      []string{},
+					MaintenanceBypassToken:     "",
+					MaintenanceEnabled:         false,
+					MaintenanceTemplatePath:    "",
+					MaintenancePollInterval:    5 * time.Second,
+					MaintenanceRetryAfter:      5 * time.Minute,
 					OTBatchTimeout:             5 * time.Second,
 					OTExporterOTLPEndpoint:     "localhost:4317",
 					OTServiceName:              "dis-redirect-proxy",
 					OtelEnabled:                false,
+					RateLimitBurst:             40,
+					RateLimitEnabled:           false,
+					RateLimitRequestsPerSecond: 20,
+					RateLimitRules:             RateLimitRules{},
+					RateLimitStore:             RateLimitStoreMemory,
+					RedirectKeyPrefix:          "",
 					RedisAddress:               "localhost:6379",
 					RedisClusterName:           "",
+					RedisConnectBackoff:        time.Second,
+					RedisConnectMaxBackoff:     time.Minute,
+					RedisMinIdleConns:          0,
+					RedisPassword:              "",
+					RedisPasswordFile:          "",
+					RedisPoolSize:              0,
+					RedisReadRouting:           RedisReadRoutingPrimary,
+					RedisReadTimeout:           3 * time.Second,
 					RedisRegion:                "",
 					RedisSecProtocol:           "",
 					RedisService:               "",
+					RedisTLSCAFile:             "",
+					RedisTLSCertFile:           "",
+					RedisTLSKeyFile:            "",
 					RedisUsername:              "",
+					RedisWriteTimeout:          3 * time.Second,
+					ResponseHeaderPolicies:     HeaderPolicies{},
+					TrustedProxyHops:           0,
+					UpstreamHeaderRules:        HeaderRules{},
 					WagtailURL:                 "http://localhost:8000",
 				})
 			})
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnvVar is the environment variable holding the path of an optional YAML or JSON config file
+const FileEnvVar = "CONFIG_FILE"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldsByKey returns the fields of config keyed by their name in a config file, which is their environment
+// variable name in lower case, e.g. bind_addr
+func (config *Config) fieldsByKey() map[string]reflect.Value {
+	v := reflect.ValueOf(config).Elem()
+	fields := make(map[string]reflect.Value, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Tag.Get("envconfig"); name != "" {
+			fields[strings.ToLower(name)] = v.Field(i)
+		}
+	}
+	return fields
+}
+
+// loadFile sets the fields of config from the YAML or JSON config file at path. Lists and objects, such as
+// cache_rules, are written as YAML or JSON rather than as a JSON string, and durations as strings such as "5s".
+// Every problem with the file is reported at once.
+func (config *Config) loadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	fields := config.fieldsByKey()
+	var problems []string
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		field, ok := fields[strings.ToLower(key)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is not a known setting", key))
+			continue
+		}
+		if err := setField(field, values[key]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %v", key, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config file %s: %s", path, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// setField sets field to a value read from a config file
+func setField(field reflect.Value, value interface{}) error {
+	if field.Type() == durationType {
+		s, ok := value.(string)
+		if !ok {
+			return errors.New("must be a duration such as \"5s\"")
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, field.Addr().Interface()); err != nil {
+		return fmt.Errorf("must be a valid %s: %w", field.Type(), err)
+	}
+	return nil
+}
+
+// WriteYAML writes the config to w in the format of a config file, so it can be used as one
+func (config *Config) WriteYAML(w io.Writer) error {
+	values := map[string]interface{}{}
+	for key, field := range config.fieldsByKey() {
+		if field.Type() == durationType {
+			values[key] = time.Duration(field.Int()).String()
+			continue
+		}
+
+		// values are converted through JSON so that nested fields use their JSON names
+		b, err := json.Marshal(field.Interface())
+		if err != nil {
+			return err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		values[key] = fromJSONNumbers(value)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(values); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// fromJSONNumbers replaces the json.Numbers in value with integers, or floats if they have a fractional part
+func fromJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = fromJSONNumbers(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = fromJSONNumbers(v[key])
+		}
+	}
+	return value
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConfigFile(t *testing.T) {
+	Convey("Given a YAML config file", t, func() {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		So(os.WriteFile(path, []byte(`
+bind_addr: localhost:31000
+healthcheck_interval: 10s
+proxy_retry_status_codes: [502, 503]
+rate_limit_requests_per_second: 2.5
+cache_rules:
+  - path_prefix: /economy
+    ttl: 5m
+upstream_header_rules:
+  legacy:
+    remove: [X-Internal]
+`), 0o600), ShouldBeNil)
+		t.Setenv(FileEnvVar, path)
+
+		Convey("When the config is loaded", func() {
+			config, err := Load()
+			So(err, ShouldBeNil)
+
+			Convey("Then the values in the file replace the defaults", func() {
+				So(config.BindAddr, ShouldEqual, "localhost:31000")
+				So(config.HealthCheckInterval, ShouldEqual, 10*time.Second)
+				So(config.ProxyRetryStatusCodes, ShouldResemble, []int{502, 503})
+				So(config.RateLimitRequestsPerSecond, ShouldEqual, 2.5)
+				So(config.CacheRules, ShouldResemble, CacheRules{{PathPrefix: "/economy", TTL: Duration(5 * time.Minute)}})
+				So(config.UpstreamHeaderRules, ShouldResemble, HeaderRules{"legacy": {Remove: []string{"X-Internal"}}})
+			})
+
+			Convey("And settings missing from the file keep their defaults", func() {
+				So(config.ProxiedServiceURL, ShouldEqual, "http://localhost:20000")
+			})
+		})
+
+		Convey("When an environment variable is also set", func() {
+			t.Setenv("BIND_ADDR", "localhost:32000")
+			config, err := Load()
+			So(err, ShouldBeNil)
+
+			Convey("Then it overrides the value in the file", func() {
+				So(config.BindAddr, ShouldEqual, "localhost:32000")
+				So(config.HealthCheckInterval, ShouldEqual, 10*time.Second)
+			})
+		})
+	})
+
+	Convey("Given a JSON config file", t, func() {
+		path := filepath.Join(t.TempDir(), "config.json")
+		So(os.WriteFile(path, []byte(`{"enable_redirects": true, "cache_max_entries": 500}`), 0o600), ShouldBeNil)
+		t.Setenv(FileEnvVar, path)
+
+		Convey("When the config is loaded", func() {
+			config, err := Load()
+			So(err, ShouldBeNil)
+
+			Convey("Then the values in the file are used", func() {
+				So(config.EnableRedirects, ShouldBeTrue)
+				So(config.CacheMaxEntries, ShouldEqual, 500)
+			})
+		})
+	})
+
+	Convey("Given a config file with several problems", t, func() {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		So(os.WriteFile(path, []byte("bind_adr: localhost:31000\ncache_max_entries: lots\nhealthcheck_interval: 10\n"), 0o600), ShouldBeNil)
+		t.Setenv(FileEnvVar, path)
+
+		Convey("When the config is loaded", func() {
+			_, err := Load()
+
+			Convey("Then every problem is reported", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "bind_adr is not a known setting")
+				So(err.Error(), ShouldContainSubstring, "cache_max_entries must be a valid int")
+				So(err.Error(), ShouldContainSubstring, `healthcheck_interval must be a duration such as "5s"`)
+			})
+		})
+	})
+
+	Convey("Given a config file that doesn't exist", t, func() {
+		t.Setenv(FileEnvVar, filepath.Join(t.TempDir(), "missing.yaml"))
+
+		Convey("When the config is loaded", func() {
+			_, err := Load()
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestWriteYAML(t *testing.T) {
+	Convey("Given a config with secrets", t, func() {
+		config, err := Load()
+		So(err, ShouldBeNil)
+		config.AdminAPIKey = "admin-secret"
+		config.CacheRules = CacheRules{{PathPrefix: "/economy", TTL: Duration(time.Minute)}}
+
+		Convey("When the redacted config is written", func() {
+			var b bytes.Buffer
+			So(config.Redacted().WriteYAML(&b), ShouldBeNil)
+
+			Convey("Then the secrets are redacted", func() {
+				So(b.String(), ShouldContainSubstring, "admin_api_key: REDACTED\n")
+				So(b.String(), ShouldNotContainSubstring, "admin-secret")
+				So(b.String(), ShouldContainSubstring, "maintenance_bypass_token: \"\"\n")
+				So(config.AdminAPIKey, ShouldEqual, "admin-secret")
+			})
+
+			Convey("And it can be read back as a config file", func() {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				So(os.WriteFile(path, b.Bytes(), 0o600), ShouldBeNil)
+				t.Setenv(FileEnvVar, path)
+
+				read, err := Load()
+				So(err, ShouldBeNil)
+				read.AdminAPIKey = config.AdminAPIKey
+				So(read, ShouldResemble, config)
+			})
+		})
+	})
+}
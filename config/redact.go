@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// redacted replaces the value of a secret wherever the config is logged, printed or encoded
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the config in which the value of every field tagged `secret:"true"` is replaced,
+// so that it can be logged or shown. Secrets that are not set are left empty, so that it is clear they are
+// missing. Secret strings, and the elements of secret string lists and maps, are replaced with "REDACTED". In
+// a secret map of structs, only the fields of the structs that are tagged as secrets are redacted; secrets of
+// any other type are cleared.
+func (config *Config) Redacted() *Config {
+	c := *config
+	redactFields(reflect.ValueOf(&c).Elem())
+	return &c
+}
+
+// redactFields replaces the secret held in each field of the struct v that is tagged `secret:"true"`
+func redactFields(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("secret") == "true" {
+			redact(v.Field(i))
+		}
+	}
+}
+
+// redact replaces the secret held in field. Slices and maps are replaced rather than modified, as they are
+// shared with the config that was copied.
+func redact(field reflect.Value) {
+	if field.IsZero() {
+		return
+	}
+
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(redacted)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		values := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+		for i := 0; i < field.Len(); i++ {
+			values.Index(i).SetString(redacted)
+		}
+		field.Set(values)
+	case field.Kind() == reflect.Map && field.Type().Elem().Kind() == reflect.String:
+		values := reflect.MakeMapWithSize(field.Type(), field.Len())
+		for _, key := range field.MapKeys() {
+			values.SetMapIndex(key, reflect.ValueOf(redacted).Convert(field.Type().Elem()))
+		}
+		field.Set(values)
+	case field.Kind() == reflect.Map && field.Type().Elem().Kind() == reflect.Struct:
+		values := reflect.MakeMapWithSize(field.Type(), field.Len())
+		for _, key := range field.MapKeys() {
+			value := reflect.New(field.Type().Elem()).Elem()
+			value.Set(field.MapIndex(key))
+			redactFields(value)
+			values.SetMapIndex(key, value)
+		}
+		field.Set(values)
+	default:
+		field.SetZero()
+	}
+}
+
+// MarshalJSON implements json.Marshaler, encoding the config with its secrets redacted so that it is safe to log.
+// It has a value receiver so that both a Config and a *Config are redacted.
+func (config Config) MarshalJSON() ([]byte, error) {
+	// fields has the fields of Config without its methods, so that it is encoded field by field
+	type fields Config
+	return json.Marshal((*fields)(config.Redacted()))
+}
+
+// String implements fmt.Stringer, returning the config as JSON with its secrets redacted
+func (config Config) String() string {
+	b, err := config.MarshalJSON()
+	if err != nil {
+		return "config: " + err.Error()
+	}
+	return string(b)
+}
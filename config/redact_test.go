@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRedacted(t *testing.T) {
+	Convey("Given a config with a secret that is set and one that isn't", t, func() {
+		config := &Config{AdminAPIKey: "admin-secret", BindAddr: "localhost:30000"}
+
+		Convey("When it is redacted", func() {
+			redactedConfig := config.Redacted()
+
+			Convey("Then the secret that is set is replaced", func() {
+				So(redactedConfig.AdminAPIKey, ShouldEqual, redacted)
+			})
+
+			Convey("And the secret that isn't set is left empty", func() {
+				So(redactedConfig.MaintenanceBypassToken, ShouldBeEmpty)
+			})
+
+			Convey("And other fields and the original config are unchanged", func() {
+				So(redactedConfig.BindAddr, ShouldEqual, "localhost:30000")
+				So(config.AdminAPIKey, ShouldEqual, "admin-secret")
+			})
+		})
+
+		Convey("When it is encoded as JSON", func() {
+			fromPointer, err := json.Marshal(config)
+			So(err, ShouldBeNil)
+			fromValue, err := json.Marshal(*config)
+			So(err, ShouldBeNil)
+
+			Convey("Then the secret is redacted", func() {
+				So(string(fromPointer), ShouldContainSubstring, `"AdminAPIKey":"REDACTED"`)
+				So(string(fromPointer), ShouldContainSubstring, `"BindAddr":"localhost:30000"`)
+				So(string(fromValue), ShouldEqual, string(fromPointer))
+			})
+		})
+
+		Convey("When it is formatted as a string", func() {
+			s := fmt.Sprintf("%v", config)
+
+			Convey("Then the secret is redacted", func() {
+				So(s, ShouldContainSubstring, `"AdminAPIKey":"REDACTED"`)
+				So(s, ShouldNotContainSubstring, "admin-secret")
+			})
+		})
+	})
+
+	Convey("Given upstream header rules that set an auth token", t, func() {
+		config := &Config{UpstreamHeaderRules: HeaderRules{
+			"wagtail": {Remove: []string{"Cookie"}, Set: map[string]string{"Authorization": "Bearer wagtail-token"}},
+		}}
+
+		Convey("When the config is encoded as JSON", func() {
+			b, err := json.Marshal(config)
+			So(err, ShouldBeNil)
+
+			Convey("Then the header values are redacted but the rest of the rule is kept", func() {
+				So(string(b), ShouldContainSubstring, `"UpstreamHeaderRules":{"wagtail":{"remove":["Cookie"],`+
+					`"set":{"Authorization":"REDACTED"}}}`)
+				So(string(b), ShouldNotContainSubstring, "wagtail-token")
+			})
+
+			Convey("And the original rules are unchanged", func() {
+				So(config.UpstreamHeaderRules["wagtail"].Set["Authorization"], ShouldEqual, "Bearer wagtail-token")
+			})
+		})
+	})
+
+	Convey("Given secrets of other types", t, func() {
+		secrets := struct {
+			List   []string
+			Map    map[string]string
+			Number int
+		}{
+			List:   []string{"one", "two"},
+			Map:    map[string]string{"user": "password"},
+			Number: 1234,
+		}
+		list, m := secrets.List, secrets.Map
+
+		Convey("When they are redacted", func() {
+			v := reflect.ValueOf(&secrets).Elem()
+			for i := 0; i < v.NumField(); i++ {
+				redact(v.Field(i))
+			}
+
+			Convey("Then strings in lists and maps are replaced, and other types cleared", func() {
+				So(secrets.List, ShouldResemble, []string{redacted, redacted})
+				So(secrets.Map, ShouldResemble, map[string]string{"user": redacted})
+				So(secrets.Number, ShouldEqual, 0)
+			})
+
+			Convey("And the original list and map are unchanged", func() {
+				So(list, ShouldResemble, []string{"one", "two"})
+				So(m, ShouldResemble, map[string]string{"user": "password"})
+			})
+		})
+	})
+}
@@ -0,0 +1,297 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+// ValidationError lists every problem found with the config, so that they can all be fixed at once
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config: %s", strings.Join(e.Problems, "; "))
+}
+
+// validator collects the problems found with the config, each naming the environment variable to change
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(name, format string, args ...interface{}) {
+	v.problems = append(v.problems, name+" "+fmt.Sprintf(format, args...))
+}
+
+// Validate checks every field of the config, returning a *ValidationError listing all the problems found, or
+// nil if there are none
+func (config *Config) Validate() error {
+	v := &validator{}
+
+	config.validateServer(v)
+	config.validateUpstreams(v)
+	config.validateCaching(v)
+	config.validateFeatures(v)
+	config.validateRedis(v)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validateServer checks the settings for the HTTP server, health check and telemetry
+func (config *Config) validateServer(v *validator) {
+	v.hostPort("BIND_ADDR", config.BindAddr)
+	if config.TrustedProxyHops < 0 {
+		v.add("TRUSTED_PROXY_HOPS", "must not be negative, got %d", config.TrustedProxyHops)
+	}
+	v.notNegative("GRACEFUL_SHUTDOWN_DRAIN", config.GracefulShutdownDrain.Seconds())
+	v.positiveDuration("GRACEFUL_SHUTDOWN_TIMEOUT", config.GracefulShutdownTimeout)
+	v.positiveDuration("HEALTHCHECK_INTERVAL", config.HealthCheckInterval)
+	v.positiveDuration("HEALTHCHECK_CRITICAL_TIMEOUT", config.HealthCheckCriticalTimeout)
+	v.upstreamCheck("LEGACY", config.HealthCheckLegacyPath, config.HealthCheckLegacyStatus, config.HealthCheckLegacySeverity)
+	v.upstreamCheck("WAGTAIL", config.HealthCheckWagtailPath, config.HealthCheckWagtailStatus, config.HealthCheckWagtailSeverity)
+	v.positiveDuration("HEALTHCHECK_UPSTREAM_TIMEOUT", config.HealthCheckUpstreamTimeout)
+	for i, policy := range config.ResponseHeaderPolicies {
+		v.pathPrefix(fmt.Sprintf("RESPONSE_HEADER_POLICIES[%d]", i), policy.PathPrefix)
+	}
+
+	if config.OtelEnabled {
+		v.required("OTEL_EXPORTER_OTLP_ENDPOINT", config.OTExporterOTLPEndpoint, "when OTEL_ENABLED is true")
+		v.required("OTEL_SERVICE_NAME", config.OTServiceName, "when OTEL_ENABLED is true")
+		v.positiveDuration("OTEL_BATCH_TIMEOUT", config.OTBatchTimeout)
+	}
+}
+
+// validateUpstreams checks the settings for forwarding requests to the upstreams
+func (config *Config) validateUpstreams(v *validator) {
+	v.url("PROXIED_SERVICE_URL", config.ProxiedServiceURL)
+	v.url("WAGTAIL_URL", config.WagtailURL)
+	for _, upstream := range slices.Sorted(maps.Keys(config.UpstreamHeaderRules)) {
+		v.oneOf("UPSTREAM_HEADER_RULES", upstream, "legacy", "wagtail")
+	}
+
+	v.notNegative("PROXY_RETRY_BUDGET_MIN_PER_SEC", float64(config.ProxyRetryBudgetMinPerSec))
+	v.notNegative("PROXY_RETRY_BUDGET_RATIO", config.ProxyRetryBudgetRatio)
+	v.positive("PROXY_RETRY_MAX_ATTEMPTS", int64(config.ProxyRetryMaxAttempts))
+	v.positiveDuration("PROXY_RETRY_INITIAL_BACKOFF", config.ProxyRetryInitialBackoff)
+	v.positiveDuration("PROXY_RETRY_MAX_BACKOFF", config.ProxyRetryMaxBackoff)
+	if config.ProxyRetryMaxBackoff < config.ProxyRetryInitialBackoff {
+		v.add("PROXY_RETRY_MAX_BACKOFF", "must not be less than PROXY_RETRY_INITIAL_BACKOFF (%s), got %s",
+			config.ProxyRetryInitialBackoff, config.ProxyRetryMaxBackoff)
+	}
+	for _, name := range config.ProxyRetryErrors {
+		v.oneOf("PROXY_RETRY_ERRORS", name, RetryErrorConnectionReset, RetryErrorConnectionRefused, RetryErrorBrokenPipe,
+			RetryErrorConnectionClosed)
+	}
+	for _, code := range config.ProxyRetryStatusCodes {
+		if code < 100 || code > 599 {
+			v.add("PROXY_RETRY_STATUS_CODES", "must hold HTTP status codes, got %d", code)
+		}
+	}
+}
+
+// validateCaching checks the settings for caching, coalescing and compressing responses
+func (config *Config) validateCaching(v *validator) {
+	v.oneOf("CACHE_STORE", config.CacheStore, CacheStoreMemory, CacheStoreRedis)
+	for _, name := range config.CacheAuthCookies {
+		if strings.TrimSpace(name) == "" {
+			v.add("CACHE_AUTH_COOKIES", "must hold cookie names, got an empty name")
+		}
+	}
+	v.positive("CACHE_MAX_BODY_SIZE", config.CacheMaxBodySize)
+	v.positive("CACHE_MAX_BYTES", config.CacheMaxBytes)
+	v.positive("CACHE_MAX_ENTRIES", int64(config.CacheMaxEntries))
+	for i, rule := range config.CacheRules {
+		name := fmt.Sprintf("CACHE_RULES[%d]", i)
+		v.pathPrefix(name, rule.PathPrefix)
+		v.notNegative(name+".ttl", time.Duration(rule.TTL).Seconds())
+		v.notNegative(name+".stale_while_revalidate", time.Duration(rule.StaleWhileRevalidate).Seconds())
+		v.notNegative(name+".stale_if_error", time.Duration(rule.StaleIfError).Seconds())
+	}
+
+	v.positive("PROXY_COALESCING_MAX_BODY_SIZE", config.ProxyCoalescingMaxBodySize)
+	v.notNegative("COMPRESSION_MIN_SIZE", float64(config.CompressionMinSize))
+}
+
+// validateFeatures checks the settings for feature flags, maintenance mode and rate limiting
+func (config *Config) validateFeatures(v *validator) {
+	v.oneOf("FEATURE_FLAGS_SOURCE", config.FeatureFlagsSource, "", FeatureFlagsSourceFile, FeatureFlagsSourceRedis)
+	if config.FeatureFlagsSource == FeatureFlagsSourceFile {
+		v.required("FEATURE_FLAGS_FILE", config.FeatureFlagsFile, fmt.Sprintf("when FEATURE_FLAGS_SOURCE is %q", FeatureFlagsSourceFile))
+	}
+	v.positiveDuration("FEATURE_FLAGS_POLL_INTERVAL", config.FeatureFlagsPollInterval)
+
+	for _, value := range config.MaintenanceAllowedIPs {
+		value = strings.TrimSpace(value)
+		if _, _, err := net.ParseCIDR(value); err != nil && net.ParseIP(value) == nil {
+			v.add("MAINTENANCE_ALLOWED_IPS", "must hold IP addresses or CIDR ranges, got %q", value)
+		}
+	}
+	v.positiveDuration("MAINTENANCE_POLL_INTERVAL", config.MaintenancePollInterval)
+	v.positiveDuration("MAINTENANCE_RETRY_AFTER", config.MaintenanceRetryAfter)
+
+	// unprefixed redirect keys are paths, so a prefix starting with / would make them impossible to tell apart
+	if strings.HasPrefix(config.RedirectKeyPrefix, "/") {
+		v.add("REDIRECT_KEY_PREFIX", "must not start with /, got %q", config.RedirectKeyPrefix)
+	}
+	// the histories of redirects are kept in the same cluster slot as the redirects with a hash tag, which braces
+	// in the prefix would change
+	if strings.ContainsAny(config.RedirectKeyPrefix, "{}") {
+		v.add("REDIRECT_KEY_PREFIX", "must not contain { or }, got %q", config.RedirectKeyPrefix)
+	}
+
+	v.oneOf("RATE_LIMIT_STORE", config.RateLimitStore, RateLimitStoreMemory, RateLimitStoreRedis)
+	v.rateLimit("RATE_LIMIT_REQUESTS_PER_SECOND", "RATE_LIMIT_BURST", config.RateLimitRequestsPerSecond, config.RateLimitBurst)
+	for i, rule := range config.RateLimitRules {
+		name := fmt.Sprintf("RATE_LIMIT_RULES[%d]", i)
+		v.pathPrefix(name, rule.PathPrefix)
+		v.rateLimit(name+".requests_per_second", name+".burst", rule.RequestsPerSecond, rule.Burst)
+	}
+}
+
+// validateRedis checks the TLS protocol, the connection backoff and pool, and that the Redis cluster settings are either
+// all set, to connect to a cluster, or all empty, to connect to REDIS_ADDRESS. Reads can only be routed to
+// replicas of a cluster, and a password can't be used with IAM authentication.
+func (config *Config) validateRedis(v *validator) {
+	v.oneOf("REDIS_SEC_PROTO", config.RedisSecProtocol, "", RedisTLSProtocol)
+	v.positiveDuration("REDIS_CONNECT_BACKOFF", config.RedisConnectBackoff)
+	v.positiveDuration("REDIS_CONNECT_MAX_BACKOFF", config.RedisConnectMaxBackoff)
+	if config.RedisConnectMaxBackoff < config.RedisConnectBackoff {
+		v.add("REDIS_CONNECT_MAX_BACKOFF", "must not be less than REDIS_CONNECT_BACKOFF (%s), got %s",
+			config.RedisConnectBackoff, config.RedisConnectMaxBackoff)
+	}
+	v.notNegative("REDIS_POOL_SIZE", float64(config.RedisPoolSize))
+	v.notNegative("REDIS_MIN_IDLE_CONNS", float64(config.RedisMinIdleConns))
+	v.positiveDuration("REDIS_READ_TIMEOUT", config.RedisReadTimeout)
+	v.positiveDuration("REDIS_WRITE_TIMEOUT", config.RedisWriteTimeout)
+	v.oneOf("REDIS_READ_ROUTING", config.RedisReadRouting,
+		RedisReadRoutingPrimary, RedisReadRoutingReplica, RedisReadRoutingRandom, RedisReadRoutingLatency)
+
+	cluster := []struct{ name, value string }{
+		{"REDIS_CLUSTER_NAME", config.RedisClusterName},
+		{"REDIS_REGION", config.RedisRegion},
+		{"REDIS_SERVICE", config.RedisService},
+	}
+	var missing []string
+	for _, setting := range cluster {
+		if setting.value == "" {
+			missing = append(missing, setting.name)
+		}
+	}
+
+	switch {
+	case len(missing) == len(cluster):
+		v.required("REDIS_ADDRESS", config.RedisAddress, "unless the Redis cluster settings are set")
+		if config.RedisReadRouting != RedisReadRoutingPrimary {
+			v.add("REDIS_READ_ROUTING", "must be %q unless the Redis cluster settings are set, got %q",
+				RedisReadRoutingPrimary, config.RedisReadRouting)
+		}
+	case len(missing) > 0:
+		v.add(strings.Join(missing, ", "), "must also be set, as REDIS_CLUSTER_NAME, REDIS_REGION and REDIS_SERVICE must all be set or all be empty")
+	}
+
+	if config.RedisPassword != "" && config.RedisPasswordFile != "" {
+		v.add("REDIS_PASSWORD, REDIS_PASSWORD_FILE", "must not both be set")
+	}
+	if (config.RedisPassword != "" || config.RedisPasswordFile != "") && len(missing) == 0 && config.RedisUsername != "" {
+		v.add("REDIS_PASSWORD", "must not be set when the Redis cluster settings and REDIS_USERNAME are set, as IAM authentication is used")
+	}
+	if (config.RedisTLSCertFile == "") != (config.RedisTLSKeyFile == "") {
+		v.add("REDIS_TLS_CERT_FILE, REDIS_TLS_KEY_FILE", "must both be set or both be empty")
+	}
+	if config.RedisSecProtocol != RedisTLSProtocol &&
+		(config.RedisTLSCAFile != "" || config.RedisTLSCertFile != "" || config.RedisTLSKeyFile != "") {
+		v.add("REDIS_SEC_PROTO", "must be %q when the Redis TLS files are set, got %q", RedisTLSProtocol, config.RedisSecProtocol)
+	}
+}
+
+func (v *validator) required(name, value, condition string) {
+	if value == "" {
+		v.add(name, "is required %s", condition)
+	}
+}
+
+func (v *validator) url(name, value string) {
+	if value == "" {
+		v.add(name, "is required")
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(name, "must be an absolute http or https URL, got %q", value)
+	}
+}
+
+func (v *validator) hostPort(name, value string) {
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		v.add(name, "must be a host and port such as \"localhost:30000\", got %q", value)
+	}
+}
+
+func (v *validator) oneOf(name, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	quoted := make([]string, len(allowed))
+	for i, a := range allowed {
+		quoted[i] = fmt.Sprintf("%q", a)
+	}
+	v.add(name, "must be one of %s, got %q", strings.Join(quoted, ", "), value)
+}
+
+func (v *validator) positive(name string, value int64) {
+	if value <= 0 {
+		v.add(name, "must be greater than zero, got %d", value)
+	}
+}
+
+func (v *validator) positiveDuration(name string, value time.Duration) {
+	if value <= 0 {
+		v.add(name, "must be a duration greater than zero, such as \"5s\", got %s", value)
+	}
+}
+
+func (v *validator) notNegative(name string, value float64) {
+	if value < 0 {
+		v.add(name, "must not be negative, got %g", value)
+	}
+}
+
+func (v *validator) pathPrefix(name, value string) {
+	if !strings.HasPrefix(value, "/") {
+		v.add(name+".path_prefix", "must start with /, got %q", value)
+	}
+}
+
+// upstreamCheck checks the settings for the health check of the named upstream
+func (v *validator) upstreamCheck(upstream, path string, status int, severity string) {
+	name := "HEALTHCHECK_" + upstream
+	if !strings.HasPrefix(path, "/") {
+		v.add(name+"_PATH", "must start with /, got %q", path)
+	}
+	if status < 100 || status > 599 {
+		v.add(name+"_STATUS", "must be an HTTP status code, got %d", status)
+	}
+	v.oneOf(name+"_SEVERITY", severity, healthcheck.StatusWarning, healthcheck.StatusCritical)
+}
+
+func (v *validator) rateLimit(rateName, burstName string, rate float64, burst int) {
+	if rate <= 0 {
+		v.add(rateName, "must be greater than zero, got %g", rate)
+	}
+	if burst < 1 {
+		v.add(burstName, "must be at least 1, got %d", burst)
+	}
+}
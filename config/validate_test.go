@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Given the default config", t, func() {
+		config, err := Load()
+		So(err, ShouldBeNil)
+
+		Convey("Then it is valid", func() {
+			So(config.Validate(), ShouldBeNil)
+		})
+
+		Convey("When several fields are invalid", func() {
+			config.BindAddr = "30000"
+			config.ProxiedServiceURL = ""
+			config.WagtailURL = "localhost:8000"
+			config.GracefulShutdownTimeout = 0
+			config.CacheMaxBytes = 0
+			config.RedisSecProtocol = "SSL"
+			config.RateLimitRules = RateLimitRules{{PathPrefix: "search", RequestsPerSecond: 1}}
+			config.UpstreamHeaderRules = HeaderRules{"zebedee": {}}
+
+			Convey("Then every problem is reported at once", func() {
+				var validationErr *ValidationError
+				So(errors.As(config.Validate(), &validationErr), ShouldBeTrue)
+				So(validationErr.Problems, ShouldResemble, []string{
+					`BIND_ADDR must be a host and port such as "localhost:30000", got "30000"`,
+					`GRACEFUL_SHUTDOWN_TIMEOUT must be a duration greater than zero, such as "5s", got 0s`,
+					`PROXIED_SERVICE_URL is required`,
+					`WAGTAIL_URL must be an absolute http or https URL, got "localhost:8000"`,
+					`UPSTREAM_HEADER_RULES must be one of "legacy", "wagtail", got "zebedee"`,
+					`CACHE_MAX_BYTES must be greater than zero, got 0`,
+					`RATE_LIMIT_RULES[0].path_prefix must start with /, got "search"`,
+					`RATE_LIMIT_RULES[0].burst must be at least 1, got 0`,
+					`REDIS_SEC_PROTO must be one of "", "TLS", got "SSL"`,
+				})
+			})
+		})
+
+		Convey("When only some of the Redis cluster settings are set", func() {
+			config.RedisClusterName = "redirects"
+			config.RedisRegion = "eu-west-2"
+
+			Convey("Then the missing settings are reported", func() {
+				So(config.Validate(), ShouldBeError, "invalid config: REDIS_SERVICE must also be set, as REDIS_CLUSTER_NAME, "+
+					"REDIS_REGION and REDIS_SERVICE must all be set or all be empty")
+			})
+		})
+
+		Convey("When all of the Redis cluster settings are set", func() {
+			config.RedisAddress = ""
+			config.RedisClusterName = "redirects"
+			config.RedisRegion = "eu-west-2"
+			config.RedisService = "elasticache"
+
+			Convey("Then the config is valid without a Redis address", func() {
+				So(config.Validate(), ShouldBeNil)
+			})
+		})
+
+		Convey("When reads are routed to replicas without the Redis cluster settings", func() {
+			config.RedisReadRouting = RedisReadRoutingReplica
+
+			Convey("Then the problem is reported", func() {
+				So(config.Validate(), ShouldBeError, `invalid config: REDIS_READ_ROUTING must be "primary" unless the Redis `+
+					`cluster settings are set, got "replica"`)
+			})
+		})
+
+		Convey("When the redirect key prefix starts with /", func() {
+			config.RedirectKeyPrefix = "/redirect"
+
+			Convey("Then the problem is reported", func() {
+				So(config.Validate(), ShouldBeError, `invalid config: REDIRECT_KEY_PREFIX must not start with /, got "/redirect"`)
+			})
+		})
+
+		Convey("When the redirect key prefix has a hash tag", func() {
+			config.RedirectKeyPrefix = "{redirect}:"
+
+			Convey("Then the problem is reported", func() {
+				So(config.Validate(), ShouldBeError, `invalid config: REDIRECT_KEY_PREFIX must not contain { or }, got "{redirect}:"`)
+			})
+		})
+
+		Convey("When a Redis password is set both directly and from a file", func() {
+			config.RedisPassword = "secret"
+			config.RedisPasswordFile = "/run/secrets/redis-password"
+
+			Convey("Then the problem is reported", func() {
+				So(config.Validate(), ShouldBeError, "invalid config: REDIS_PASSWORD, REDIS_PASSWORD_FILE must not both be set")
+			})
+		})
+
+		Convey("When a Redis password is set along with IAM authentication", func() {
+			config.RedisClusterName = "redirects"
+			config.RedisRegion = "eu-west-2"
+			config.RedisService = "elasticache"
+			config.RedisUsername = "proxy"
+			config.RedisPassword = "secret"
+
+			Convey("Then the problem is reported", func() {
+				So(config.Validate(), ShouldBeError, "invalid config: REDIS_PASSWORD must not be set when the Redis cluster "+
+					"settings and REDIS_USERNAME are set, as IAM authentication is used")
+			})
+		})
+
+		Convey("When a Redis client certificate is set without TLS or its key", func() {
+			config.RedisTLSCertFile = "/etc/redis/client.crt"
+
+			Convey("Then both problems are reported", func() {
+				var validationErr *ValidationError
+				So(errors.As(config.Validate(), &validationErr), ShouldBeTrue)
+				So(validationErr.Problems, ShouldResemble, []string{
+					`REDIS_TLS_CERT_FILE, REDIS_TLS_KEY_FILE must both be set or both be empty`,
+					`REDIS_SEC_PROTO must be "TLS" when the Redis TLS files are set, got ""`,
+				})
+			})
+		})
+
+		Convey("When a cache auth cookie name is empty", func() {
+			config.CacheAuthCookies = []string{"access_token", ""}
+
+			Convey("Then the problem is reported", func() {
+				So(config.Validate(), ShouldBeError, "invalid config: CACHE_AUTH_COOKIES must hold cookie names, got an empty name")
+			})
+		})
+
+		Convey("When a retryable error isn't one that can be retried", func() {
+			config.ProxyRetryErrors = []string{"connection_reset", "timeout"}
+
+			Convey("Then the problem is reported", func() {
+				So(config.Validate(), ShouldBeError, `invalid config: PROXY_RETRY_ERRORS must be one of "connection_reset", `+
+					`"connection_refused", "broken_pipe", "connection_closed", got "timeout"`)
+			})
+		})
+
+		Convey("When the maximum retry backoff is less than the initial backoff", func() {
+			config.ProxyRetryMaxBackoff = 10 * time.Millisecond
+
+			Convey("Then the problem is reported", func() {
+				So(config.Validate(), ShouldBeError, "invalid config: PROXY_RETRY_MAX_BACKOFF must not be less than "+
+					"PROXY_RETRY_INITIAL_BACKOFF (50ms), got 10ms")
+			})
+		})
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	goerrors "errors"
+	"testing"
+
+	"github.com/ONSdigital/dis-redirect-proxy/service"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExitCode(t *testing.T) {
+	Convey("Given the errors that the service can fail with", t, func() {
+		cases := []struct {
+			name string
+			err  error
+			code int
+		}{
+			{"an error getting the configuration", withExitCode(exitConfigError, errors.New("REDIS_ADDRESS is required")), exitConfigError},
+			{"an error starting the service", withExitCode(exitStartupError, errors.New("address already in use")), exitStartupError},
+			{"an error from the running service", withExitCode(exitRuntimeError, errors.New("server closed")), exitRuntimeError},
+			{"an error without an exit code", errors.New("unexpected"), exitRuntimeError},
+			{"a wrapped error with an exit code", errors.Wrap(withExitCode(exitConfigError, errors.New("invalid")), "error running"), exitConfigError},
+			{"a joined error with an exit code", goerrors.Join(errors.New("shutdown"), withExitCode(exitStartupError, errors.New("listen"))), exitStartupError},
+		}
+
+		for _, tc := range cases {
+			Convey("When the service fails with "+tc.name, func() {
+				code := exitCode(tc.err)
+
+				Convey("Then the process exits with the code for it", func() {
+					So(code, ShouldEqual, tc.code)
+				})
+			})
+		}
+	})
+
+	Convey("Given an error given an exit code", t, func() {
+		cause := errors.New("address already in use")
+		err := withExitCode(exitStartupError, cause)
+
+		Convey("Then the error reads as, and unwraps to, its cause", func() {
+			So(err, ShouldBeError, "address already in use")
+			So(goerrors.Is(err, cause), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given no error", t, func() {
+		Convey("Then giving it an exit code still returns no error", func() {
+			So(withExitCode(exitStartupError, nil), ShouldBeNil)
+		})
+	})
+}
+
+func TestServiceErrorCode(t *testing.T) {
+	Convey("Given the HTTP server couldn't listen on its bind address", t, func() {
+		err := errors.Wrap(&service.ListenError{Err: errors.New("listen tcp :30000: bind: address already in use")}, "service error received")
+
+		Convey("Then the process exits with the startup error code", func() {
+			So(serviceErrorCode(err), ShouldEqual, exitStartupError)
+		})
+	})
+
+	Convey("Given the HTTP server failed while it was running", t, func() {
+		err := errors.Wrap(errors.New("server closed"), "service error received")
+
+		Convey("Then the process exits with the runtime error code", func() {
+			So(serviceErrorCode(err), ShouldEqual, exitRuntimeError)
+		})
+	})
+}
@@ -50,6 +50,9 @@ func NewProxyComponent(redisFeat *componentTest.RedisFeature, proxiedServiceFeat
 		return nil, err
 	}
 
+	// scenarios don't have a load balancer to drain traffic from
+	c.Config.GracefulShutdownDrain = 0
+
 	c.proxiedServiceFeature = proxiedServiceFeat
 	c.Config.ProxiedServiceURL = c.proxiedServiceFeature.Server.URL
 
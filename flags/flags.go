@@ -0,0 +1,196 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	disRedis "github.com/ONSdigital/dis-redis"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Names of the feature flags
+const (
+	EnableRedirects        = "enable_redirects"
+	EnableReleasesFallback = "enable_releases_fallback"
+)
+
+// RedisKey is the Redis key holding the JSON object of feature flags, shared by all proxy instances
+const RedisKey = "feature_flags"
+
+// Source provides the current values of feature flags. Flags missing from the source keep their defaults.
+type Source interface {
+	Load(ctx context.Context) (map[string]bool, error)
+}
+
+// Flags holds feature flags that can be changed at runtime. Each flag starts with a default, taken from the
+// static configuration, which is overridden by the value in the dynamic source, if there is one. The source
+// is polled for changes, and if it can't be read the last values read are kept.
+type Flags struct {
+	defaults     map[string]bool
+	source       Source
+	pollInterval time.Duration
+	current      atomic.Pointer[map[string]bool]
+	stop         chan struct{}
+	stopOnce     sync.Once
+}
+
+// New creates Flags from the service configuration
+func New(cfg *config.Config, redisCli clients.Redis) (*Flags, error) {
+	defaults := map[string]bool{
+		EnableRedirects:        cfg.EnableRedirects,
+		EnableReleasesFallback: cfg.EnableReleasesFallback,
+	}
+
+	var source Source
+	switch cfg.FeatureFlagsSource {
+	case "":
+	case config.FeatureFlagsSourceRedis:
+		if redisCli == nil {
+			return nil, errors.New("redis feature flags source requires a redis client")
+		}
+		source = &RedisSource{client: redisCli}
+	case config.FeatureFlagsSourceFile:
+		if cfg.FeatureFlagsFile == "" {
+			return nil, errors.New("file feature flags source requires a file path")
+		}
+		source = &FileSource{path: cfg.FeatureFlagsFile}
+	default:
+		return nil, fmt.Errorf("unknown feature flags source: %q", cfg.FeatureFlagsSource)
+	}
+
+	return NewWithSource(defaults, source, cfg.FeatureFlagsPollInterval), nil
+}
+
+// NewWithSource creates Flags with the given defaults, overridden by source if it isn't nil
+func NewWithSource(defaults map[string]bool, source Source, pollInterval time.Duration) *Flags {
+	f := &Flags{
+		defaults:     defaults,
+		source:       source,
+		pollInterval: pollInterval,
+		stop:         make(chan struct{}),
+	}
+	current := maps.Clone(defaults)
+	f.current.Store(&current)
+	return f
+}
+
+// Enabled reports whether the named flag is currently enabled. Unknown flags are disabled.
+func (f *Flags) Enabled(name string) bool {
+	return (*f.current.Load())[name]
+}
+
+// Start loads the flags from the source and then keeps them up to date by polling it until Stop is called
+func (f *Flags) Start(ctx context.Context) {
+	if f.source == nil {
+		return
+	}
+	if err := f.refresh(ctx); err != nil {
+		log.Error(ctx, "failed to load feature flags, using defaults", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(f.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := f.refresh(ctx); err != nil {
+					log.Error(ctx, "failed to refresh feature flags, keeping previous values", err)
+				}
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling the source for changes
+func (f *Flags) Stop() {
+	f.stopOnce.Do(func() { close(f.stop) })
+}
+
+// refresh loads the flags from the source, logging any that have changed
+func (f *Flags) refresh(ctx context.Context) error {
+	values, err := f.source.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	next := maps.Clone(f.defaults)
+	maps.Copy(next, values)
+
+	previous := *f.current.Load()
+	for name, enabled := range next {
+		if previous[name] != enabled {
+			log.Info(ctx, "feature flag changed", log.Data{"flag": name, "enabled": enabled})
+		}
+	}
+	f.current.Store(&next)
+	return nil
+}
+
+// RedisSource reads feature flags from a JSON object stored in Redis under RedisKey
+type RedisSource struct {
+	client clients.Redis
+}
+
+// Load implements Source
+func (s *RedisSource) Load(ctx context.Context) (map[string]bool, error) {
+	value, err := s.client.GetValue(ctx, RedisKey)
+	if errors.Is(err, disRedis.ErrKeyNotFound) {
+		return map[string]bool{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	values := map[string]bool{}
+	if err := json.Unmarshal([]byte(value), &values); err != nil {
+		return nil, fmt.Errorf("invalid feature flags in redis: %w", err)
+	}
+	return values, nil
+}
+
+// FileSource reads feature flags from a JSON object in a file. The file is only read again when its
+// modification time changes.
+type FileSource struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	values  map[string]bool
+}
+
+// Load implements Source
+func (s *FileSource) Load(_ context.Context) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feature flags file: %w", err)
+	}
+	if s.values != nil && info.ModTime().Equal(s.modTime) {
+		return s.values, nil
+	}
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feature flags file: %w", err)
+	}
+	values := map[string]bool{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("invalid feature flags file: %w", err)
+	}
+
+	s.modTime = info.ModTime()
+	s.values = values
+	return values, nil
+}
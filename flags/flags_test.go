@@ -0,0 +1,120 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients/mock"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	disRedis "github.com/ONSdigital/dis-redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFlags(t *testing.T) {
+	Convey("Given feature flags with defaults from the configuration", t, func() {
+		ctx := context.Background()
+		cfg := &config.Config{EnableRedirects: true, FeatureFlagsPollInterval: time.Hour}
+
+		Convey("When there is no dynamic source", func() {
+			f, err := New(cfg, nil)
+			So(err, ShouldBeNil)
+			f.Start(ctx)
+
+			Convey("Then the defaults are used", func() {
+				So(f.Enabled(EnableRedirects), ShouldBeTrue)
+				So(f.Enabled(EnableReleasesFallback), ShouldBeFalse)
+				So(f.Enabled("unknown"), ShouldBeFalse)
+			})
+		})
+
+		Convey("When the flags are kept in Redis", func() {
+			var (
+				value    = `{"enable_releases_fallback": true}`
+				redisErr error
+			)
+			redisMock := &mock.RedisMock{
+				GetValueFunc: func(ctx context.Context, key string) (string, error) {
+					So(key, ShouldEqual, RedisKey)
+					return value, redisErr
+				},
+			}
+			cfg.FeatureFlagsSource = config.FeatureFlagsSourceRedis
+			f, err := New(cfg, redisMock)
+			So(err, ShouldBeNil)
+			f.Start(ctx)
+			defer f.Stop()
+
+			Convey("Then flags set in Redis override the defaults", func() {
+				So(f.Enabled(EnableRedirects), ShouldBeTrue)
+				So(f.Enabled(EnableReleasesFallback), ShouldBeTrue)
+			})
+
+			Convey("And changes in Redis are picked up on refresh", func() {
+				value = `{"enable_redirects": false}`
+				So(f.refresh(ctx), ShouldBeNil)
+				So(f.Enabled(EnableRedirects), ShouldBeFalse)
+				So(f.Enabled(EnableReleasesFallback), ShouldBeFalse)
+			})
+
+			Convey("And removing the key restores the defaults", func() {
+				redisErr = disRedis.ErrKeyNotFound
+				So(f.refresh(ctx), ShouldBeNil)
+				So(f.Enabled(EnableRedirects), ShouldBeTrue)
+				So(f.Enabled(EnableReleasesFallback), ShouldBeFalse)
+			})
+
+			Convey("And the previous values are kept if Redis fails", func() {
+				redisErr = errors.New("redis unavailable")
+				So(f.refresh(ctx), ShouldNotBeNil)
+				So(f.Enabled(EnableReleasesFallback), ShouldBeTrue)
+			})
+
+			Convey("And the previous values are kept if Redis holds invalid flags", func() {
+				value = `{"enable_releases_fallback": "yes"}`
+				So(f.refresh(ctx), ShouldNotBeNil)
+				So(f.Enabled(EnableReleasesFallback), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the flags are kept in a file", func() {
+			path := filepath.Join(t.TempDir(), "flags.json")
+			So(os.WriteFile(path, []byte(`{"enable_redirects": false}`), 0o600), ShouldBeNil)
+			cfg.FeatureFlagsSource = config.FeatureFlagsSourceFile
+			cfg.FeatureFlagsFile = path
+			f, err := New(cfg, nil)
+			So(err, ShouldBeNil)
+			f.Start(ctx)
+			defer f.Stop()
+
+			Convey("Then flags set in the file override the defaults", func() {
+				So(f.Enabled(EnableRedirects), ShouldBeFalse)
+			})
+
+			Convey("And changes to the file are picked up on refresh", func() {
+				So(os.WriteFile(path, []byte(`{"enable_redirects": true}`), 0o600), ShouldBeNil)
+				So(os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)), ShouldBeNil)
+				So(f.refresh(ctx), ShouldBeNil)
+				So(f.Enabled(EnableRedirects), ShouldBeTrue)
+			})
+
+			Convey("And the previous values are kept if the file is removed", func() {
+				So(os.Remove(path), ShouldBeNil)
+				So(f.refresh(ctx), ShouldNotBeNil)
+				So(f.Enabled(EnableRedirects), ShouldBeFalse)
+			})
+		})
+
+		Convey("When the source is unknown", func() {
+			cfg.FeatureFlagsSource = "etcd"
+			_, err := New(cfg, nil)
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
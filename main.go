@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	goerrors "errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/metrics"
 	"github.com/ONSdigital/dis-redirect-proxy/service"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/pkg/errors"
@@ -17,6 +19,13 @@ import (
 
 const serviceName = "dis-redirect-proxy"
 
+// Exit codes, so that a failure to start can be told apart from a failure of a running service
+const (
+	exitRuntimeError = 1
+	exitConfigError  = 2
+	exitStartupError = 3
+)
+
 var (
 	// BuildTime represents the time in which the service was built
 	BuildTime string
@@ -30,15 +39,67 @@ func main() {
 	log.Namespace = serviceName
 	ctx := context.Background()
 
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := run(ctx); err != nil {
 		log.Fatal(ctx, "fatal runtime error", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
+// exitError is an error that the process exits with a specific code for
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode returns err so that the process exits with code, or nil if err is nil
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the code that the process exits with for err, which is exitRuntimeError unless another
+// code was given to err with withExitCode
+func exitCode(err error) int {
+	var exitErr *exitError
+	if goerrors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitRuntimeError
+}
+
+// serviceErrorCode returns the code that the process exits with for a fatal error from the running service,
+// which is exitStartupError if the HTTP server couldn't listen on its bind address
+func serviceErrorCode(err error) int {
+	var listenErr *service.ListenError
+	if goerrors.As(err, &listenErr) {
+		return exitStartupError
+	}
+	return exitRuntimeError
+}
+
 func run(ctx context.Context) error {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
 
 	// Run the service, providing an error channel for fatal errors
 	svcErrors := make(chan error, 1)
@@ -49,41 +110,71 @@ func run(ctx context.Context) error {
 	// Read config
 	cfg, err := config.Get()
 	if err != nil {
-		return errors.Wrap(err, "error getting configuration")
+		return withExitCode(exitConfigError, errors.Wrap(err, "error getting configuration"))
 	}
 
-	if cfg.OtelEnabled {
-		// Set up OpenTelemetry
-		otelConfig := dpotelgo.Config{
-			OtelServiceName:          cfg.OTServiceName,
-			OtelExporterOtlpEndpoint: cfg.OTExporterOTLPEndpoint,
-			OtelBatchTimeout:         cfg.OTBatchTimeout,
-		}
-
-		otelShutdown, oErr := dpotelgo.SetupOTelSDK(ctx, otelConfig)
-		if oErr != nil {
-			log.Fatal(ctx, "error setting up OpenTelemetry - hint: ensure OTEL_EXPORTER_OTLP_ENDPOINT is set", oErr)
-		}
-		// Handle shutdown properly so nothing leaks.
-		defer func() {
-			err = goerrors.Join(err, otelShutdown(context.Background()))
-		}()
+	shutdownTelemetry, err := setupTelemetry(ctx, cfg)
+	if err != nil {
+		return withExitCode(exitStartupError, err)
 	}
 
 	// Start service
 	svc, err := service.Run(ctx, cfg, svcList, BuildTime, GitCommit, Version, svcErrors)
 	if err != nil {
-		return errors.Wrap(err, "running service failed")
+		err = errors.Wrap(err, "running service failed")
+		return withExitCode(exitStartupError, goerrors.Join(err, shutdownTelemetry(ctx)))
+	}
+	// the telemetry is flushed by svc.Close once the service has shut down, so that the final requests are exported
+	svc.ShutdownTelemetry = shutdownTelemetry
+
+	// blocks until an os interrupt or a fatal error occurs, reloading the config on SIGHUP
+	for {
+		select {
+		case err := <-svcErrors:
+			// the service's dependencies are shut down cleanly, but without draining traffic, as the server has failed
+			err = errors.Wrap(err, "service error received")
+			log.Error(ctx, "fatal service error, shutting down", err)
+			return withExitCode(serviceErrorCode(err), goerrors.Join(err, svc.CloseAfterError(ctx)))
+		case sig := <-reloads:
+			log.Info(ctx, "os signal received, reloading config", log.Data{"signal": sig})
+			// a failed reload is logged and leaves the current config in place
+			_ = svc.Reload(ctx)
+		case sig := <-signals:
+			log.Info(ctx, "os signal received", log.Data{"signal": sig})
+			return withExitCode(exitRuntimeError, svc.Close(ctx))
+		}
+	}
+}
+
+// setupTelemetry sets up the OpenTelemetry traces and metrics exporters, if they are enabled, returning a
+// function that flushes and stops them
+func setupTelemetry(ctx context.Context, cfg *config.Config) (shutdown func(context.Context) error, err error) {
+	if !cfg.OtelEnabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	otelConfig := dpotelgo.Config{
+		OtelServiceName:          cfg.OTServiceName,
+		OtelExporterOtlpEndpoint: cfg.OTExporterOTLPEndpoint,
+		OtelBatchTimeout:         cfg.OTBatchTimeout,
+	}
+
+	otelShutdown, err := dpotelgo.SetupOTelSDK(ctx, otelConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "error setting up OpenTelemetry - hint: ensure OTEL_EXPORTER_OTLP_ENDPOINT is set")
 	}
 
-	// blocks until an os interrupt or a fatal error occurs
-	select {
-	case err := <-svcErrors:
-		// TODO: call svc.Close(ctx) (or something specific)
-		//  if there are any service connections like Kafka that you need to shut down
-		return errors.Wrap(err, "service error received")
-	case sig := <-signals:
-		log.Info(ctx, "os signal received", log.Data{"signal": sig})
+	metricsShutdown, err := metrics.Setup(ctx, metrics.Config{
+		ServiceName:      cfg.OTServiceName,
+		ExporterEndpoint: cfg.OTExporterOTLPEndpoint,
+		ExportInterval:   cfg.OTBatchTimeout,
+	})
+	if err != nil {
+		err = errors.Wrap(err, "error setting up OpenTelemetry metrics")
+		return nil, goerrors.Join(err, otelShutdown(ctx))
 	}
-	return svc.Close(ctx)
+
+	return func(ctx context.Context) error {
+		return goerrors.Join(metricsShutdown(ctx), otelShutdown(ctx))
+	}, nil
 }
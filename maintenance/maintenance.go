@@ -0,0 +1,324 @@
+package maintenance
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/middleware"
+	"github.com/ONSdigital/dis-redirect-proxy/response"
+	disRedis "github.com/ONSdigital/dis-redis"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// RedisKey is the Redis key holding the JSON array of maintenance windows, shared by all proxy instances
+	RedisKey = "maintenance"
+
+	// BypassHeader lets a request through maintenance if it holds the configured bypass token
+	BypassHeader = "X-Maintenance-Bypass"
+
+	defaultMessage = "This part of the website is unavailable while we carry out maintenance. Please try again later."
+)
+
+// ErrInvalidPathPrefix is returned when a maintenance window's path prefix doesn't start with /
+var ErrInvalidPathPrefix = errors.New("path prefix must start with /")
+
+// Window puts the part of the site under PathPrefix into maintenance. RetryAfter and Message override the
+// configured Retry-After and the default maintenance message.
+type Window struct {
+	PathPrefix string          `json:"path_prefix"`
+	RetryAfter config.Duration `json:"retry_after,omitempty"`
+	Message    string          `json:"message,omitempty"`
+}
+
+// Mode serves a maintenance page for parts of the site that are in maintenance. Windows are kept in Redis so
+// that they apply to every instance and can be switched on and off at runtime, either by setting RedisKey
+// directly or through the admin API. Each instance polls Redis for changes.
+type Mode struct {
+	redis        clients.Redis
+	page         *response.ErrorPage
+	allowedIPs   []*net.IPNet
+	bypassToken  string
+	trustedHops  int
+	retryAfter   time.Duration
+	pollInterval time.Duration
+
+	// mu serialises changes to the windows made through this instance
+	mu       sync.Mutex
+	windows  atomic.Pointer[[]Window]
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a Mode from the service configuration. redisCli may be nil, in which case windows only apply
+// to this instance and are lost on restart.
+func New(cfg *config.Config, redisCli clients.Redis) (*Mode, error) {
+	page, err := response.NewErrorPage(cfg.MaintenanceTemplatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load maintenance page: %w", err)
+	}
+
+	allowedIPs, err := parseAllowedIPs(cfg.MaintenanceAllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mode{
+		redis:        redisCli,
+		page:         page,
+		allowedIPs:   allowedIPs,
+		bypassToken:  cfg.MaintenanceBypassToken,
+		trustedHops:  cfg.TrustedProxyHops,
+		retryAfter:   cfg.MaintenanceRetryAfter,
+		pollInterval: cfg.MaintenancePollInterval,
+		stop:         make(chan struct{}),
+	}
+	m.windows.Store(&[]Window{})
+	return m, nil
+}
+
+// parseAllowedIPs parses a list of IP addresses and CIDR ranges
+func parseAllowedIPs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid maintenance allowed IP: %q", value)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance allowed IP range: %q: %w", value, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// Start loads the current windows and then keeps them up to date by polling Redis until Stop is called
+func (m *Mode) Start(ctx context.Context) {
+	if m.redis == nil {
+		return
+	}
+	if err := m.refresh(ctx); err != nil {
+		log.Error(ctx, "failed to load maintenance windows", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.refresh(ctx); err != nil {
+					log.Error(ctx, "failed to refresh maintenance windows, keeping previous windows", err)
+				}
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops polling Redis for changes
+func (m *Mode) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// Windows returns the current maintenance windows
+func (m *Mode) Windows() []Window {
+	return slices.Clone(*m.windows.Load())
+}
+
+// Enable puts the part of the site under the window's path prefix into maintenance, replacing any existing
+// window for the same prefix
+func (m *Mode) Enable(ctx context.Context, window Window) error {
+	if !strings.HasPrefix(window.PathPrefix, "/") {
+		return ErrInvalidPathPrefix
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refresh(ctx); err != nil {
+		return err
+	}
+	windows := slices.DeleteFunc(m.Windows(), func(w Window) bool { return w.PathPrefix == window.PathPrefix })
+	windows = append(windows, window)
+	if err := m.save(ctx, windows); err != nil {
+		return err
+	}
+
+	log.Info(ctx, "maintenance enabled", log.Data{"path_prefix": window.PathPrefix})
+	return nil
+}
+
+// Disable takes the part of the site under pathPrefix out of maintenance, returning false if it wasn't in
+// maintenance
+func (m *Mode) Disable(ctx context.Context, pathPrefix string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.refresh(ctx); err != nil {
+		return false, err
+	}
+	current := m.Windows()
+	windows := slices.DeleteFunc(slices.Clone(current), func(w Window) bool { return w.PathPrefix == pathPrefix })
+	if len(windows) == len(current) {
+		return false, nil
+	}
+	if err := m.save(ctx, windows); err != nil {
+		return false, err
+	}
+
+	log.Info(ctx, "maintenance disabled", log.Data{"path_prefix": pathPrefix})
+	return true, nil
+}
+
+// refresh loads the windows from Redis
+func (m *Mode) refresh(ctx context.Context) error {
+	if m.redis == nil {
+		return nil
+	}
+
+	value, err := m.redis.GetValue(ctx, RedisKey)
+	if errors.Is(err, disRedis.ErrKeyNotFound) {
+		m.windows.Store(&[]Window{})
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	windows := []Window{}
+	if err := json.Unmarshal([]byte(value), &windows); err != nil {
+		return fmt.Errorf("invalid maintenance windows in redis: %w", err)
+	}
+	m.windows.Store(&windows)
+	return nil
+}
+
+// save writes the windows to Redis and makes them current
+func (m *Mode) save(ctx context.Context, windows []Window) error {
+	if m.redis != nil {
+		if len(windows) == 0 {
+			if err := m.redis.DeleteValue(ctx, RedisKey); err != nil && !errors.Is(err, disRedis.ErrKeyNotFound) {
+				return err
+			}
+		} else {
+			value, err := json.Marshal(windows)
+			if err != nil {
+				return err
+			}
+			if err := m.redis.SetValue(ctx, RedisKey, value, 0); err != nil {
+				return err
+			}
+		}
+	}
+	m.windows.Store(&windows)
+	return nil
+}
+
+// Middleware returns a middleware that responds with 503 Service Unavailable, a Retry-After header and the
+// maintenance page to requests for parts of the site that are in maintenance. Requests from allowed IPs, or
+// with the bypass token, are let through, as are requests for paths under exemptPrefixes.
+func (m *Mode) Middleware(exemptPrefixes ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			for _, prefix := range exemptPrefixes {
+				if underPrefix(req.URL.Path, prefix) {
+					next.ServeHTTP(w, req)
+					return
+				}
+			}
+
+			window := matchWindow(*m.windows.Load(), req.URL.Path)
+			bypass := window != nil && m.bypass(req)
+			// the bypass token is a secret, so it isn't forwarded to the upstreams
+			req.Header.Del(BypassHeader)
+			if window == nil || bypass {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			retryAfter := m.retryAfter
+			if window.RetryAfter > 0 {
+				retryAfter = time.Duration(window.RetryAfter)
+			}
+			message := defaultMessage
+			if window.Message != "" {
+				message = window.Message
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			m.page.Write(req.Context(), w, req, http.StatusServiceUnavailable, message)
+		})
+	}
+}
+
+// bypass reports whether the request may reach the upstream despite maintenance
+func (m *Mode) bypass(req *http.Request) bool {
+	if m.bypassToken != "" {
+		token := req.Header.Get(BypassHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(m.bypassToken)) == 1 {
+			return true
+		}
+	}
+
+	if len(m.allowedIPs) == 0 {
+		return false
+	}
+	ip := net.ParseIP(middleware.ClientIP(req, m.trustedHops))
+	if ip == nil {
+		return false
+	}
+	for _, allowed := range m.allowedIPs {
+		if allowed.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWindow returns the window with the longest path prefix matching path, or nil if none match
+func matchWindow(windows []Window, path string) *Window {
+	var match *Window
+	for i := range windows {
+		window := &windows[i]
+		if underPrefix(path, window.PathPrefix) && (match == nil || len(window.PathPrefix) > len(match.PathPrefix)) {
+			match = window
+		}
+	}
+	return match
+}
+
+// underPrefix reports whether path is prefix or is under it, matching whole path segments so that /economy matches
+// /economy/inflation but not /economyx
+func underPrefix(path, prefix string) bool {
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
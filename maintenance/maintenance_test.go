@@ -0,0 +1,224 @@
+package maintenance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients/mock"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	disRedis "github.com/ONSdigital/dis-redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newRedisMock(values map[string]string) *mock.RedisMock {
+	return &mock.RedisMock{
+		GetValueFunc: func(ctx context.Context, key string) (string, error) {
+			value, ok := values[key]
+			if !ok {
+				return "", disRedis.ErrKeyNotFound
+			}
+			return value, nil
+		},
+		SetValueFunc: func(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+			values[key] = string(value.([]byte))
+			return nil
+		},
+		DeleteValueFunc: func(ctx context.Context, key string) error {
+			delete(values, key)
+			return nil
+		},
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	Convey("Given maintenance mode with a window for part of the site", t, func() {
+		ctx := context.Background()
+		cfg := &config.Config{
+			MaintenanceAllowedIPs:   []string{"10.0.0.0/8", "192.168.0.1"},
+			MaintenanceBypassToken:  "let-me-in",
+			MaintenancePollInterval: time.Hour,
+			MaintenanceRetryAfter:   5 * time.Minute,
+		}
+		values := map[string]string{RedisKey: `[{"path_prefix":"/economy"}]`}
+		m, err := New(cfg, newRedisMock(values))
+		So(err, ShouldBeNil)
+		m.Start(ctx)
+		defer m.Stop()
+
+		var upstreamHeader http.Header
+		handler := m.Middleware("/admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			upstreamHeader = r.Header
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		serve := func(req *http.Request) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+		newRequest := func(path string) *http.Request {
+			req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+			req.RemoteAddr = "8.8.8.8:1234"
+			return req
+		}
+
+		Convey("When a page in maintenance is requested", func() {
+			w := serve(newRequest("/economy/inflation"))
+
+			Convey("Then the maintenance page is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(w.Header().Get("Retry-After"), ShouldEqual, "300")
+				So(w.Body.String(), ShouldContainSubstring, defaultMessage)
+			})
+		})
+
+		Convey("When a page outside the window is requested", func() {
+			w := serve(newRequest("/people"))
+
+			Convey("Then it reaches the upstream", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When a page that only starts with the same characters as the window is requested", func() {
+			w := serve(newRequest("/economyx"))
+
+			Convey("Then it reaches the upstream", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When the whole site is put into maintenance", func() {
+			So(m.Enable(ctx, Window{PathPrefix: "/"}), ShouldBeNil)
+
+			Convey("Then the exempt path prefix and the paths under it reach the upstream", func() {
+				So(serve(newRequest("/admin")).Code, ShouldEqual, http.StatusOK)
+				So(serve(newRequest("/admin/maintenance")).Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And a path that only starts with the same characters as the exempt prefix doesn't", func() {
+				So(serve(newRequest("/admin-foo")).Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(serve(newRequest("/administration")).Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+
+		Convey("When a page in maintenance is requested from an allowed IP", func() {
+			req := newRequest("/economy")
+			req.RemoteAddr = "10.1.2.3:1234"
+			w := serve(req)
+
+			Convey("Then it reaches the upstream", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When a page in maintenance is requested with the bypass token", func() {
+			req := newRequest("/economy")
+			req.Header.Set(BypassHeader, "let-me-in")
+			w := serve(req)
+
+			Convey("Then it reaches the upstream without the token", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(upstreamHeader.Get(BypassHeader), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a page in maintenance is requested with the wrong bypass token", func() {
+			req := newRequest("/economy")
+			req.Header.Set(BypassHeader, "guess")
+			w := serve(req)
+
+			Convey("Then the maintenance page is returned", func() {
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+
+		Convey("When maintenance is enabled for the whole site", func() {
+			err := m.Enable(ctx, Window{PathPrefix: "/", RetryAfter: config.Duration(time.Minute), Message: "Back soon"})
+			So(err, ShouldBeNil)
+
+			Convey("Then it is stored in Redis", func() {
+				So(values[RedisKey], ShouldEqual, `[{"path_prefix":"/economy"},{"path_prefix":"/","retry_after":"1m0s","message":"Back soon"}]`)
+			})
+
+			Convey("And other pages return the window's maintenance page", func() {
+				w := serve(newRequest("/people"))
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(w.Header().Get("Retry-After"), ShouldEqual, "60")
+				So(w.Body.String(), ShouldContainSubstring, "Back soon")
+			})
+
+			Convey("And exempt paths still reach the upstream", func() {
+				So(serve(newRequest("/admin/maintenance")).Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When maintenance is disabled", func() {
+			disabled, err := m.Disable(ctx, "/economy")
+			So(err, ShouldBeNil)
+
+			Convey("Then the window is removed from Redis", func() {
+				So(disabled, ShouldBeTrue)
+				So(values, ShouldNotContainKey, RedisKey)
+				So(serve(newRequest("/economy")).Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When maintenance is disabled for a prefix that isn't in maintenance", func() {
+			disabled, err := m.Disable(ctx, "/people")
+
+			Convey("Then nothing is changed", func() {
+				So(err, ShouldBeNil)
+				So(disabled, ShouldBeFalse)
+				So(m.Windows(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When a window is set directly in Redis", func() {
+			values[RedisKey] = `[{"path_prefix":"/people"}]`
+			So(m.refresh(ctx), ShouldBeNil)
+
+			Convey("Then it replaces the previous windows", func() {
+				So(serve(newRequest("/people")).Code, ShouldEqual, http.StatusServiceUnavailable)
+				So(serve(newRequest("/economy")).Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When Redis holds invalid windows", func() {
+			values[RedisKey] = `not json`
+			err := m.refresh(ctx)
+
+			Convey("Then the previous windows are kept", func() {
+				So(err, ShouldNotBeNil)
+				So(serve(newRequest("/economy")).Code, ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+	})
+}
+
+func TestParseAllowedIPs(t *testing.T) {
+	Convey("Given a list of allowed IPs", t, func() {
+		Convey("When it holds addresses and ranges", func() {
+			nets, err := parseAllowedIPs([]string{"10.0.0.1", "192.168.0.0/16", "2001:db8::1"})
+
+			Convey("Then they are all parsed", func() {
+				So(err, ShouldBeNil)
+				So(nets, ShouldHaveLength, 3)
+				So(nets[0].String(), ShouldEqual, "10.0.0.1/32")
+				So(nets[1].String(), ShouldEqual, "192.168.0.0/16")
+				So(nets[2].String(), ShouldEqual, "2001:db8::1/128")
+			})
+		})
+
+		Convey("When it holds an invalid address", func() {
+			_, err := parseAllowedIPs([]string{"not-an-ip"})
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+const meterName = "github.com/ONSdigital/dis-redirect-proxy"
+
+// Config holds the settings needed to export metrics via OTLP
+type Config struct {
+	ServiceName      string
+	ExporterEndpoint string
+	ExportInterval   time.Duration
+}
+
+// Setup registers a global OpenTelemetry meter provider that exports to the configured OTLP endpoint.
+// Instruments recorded before Setup is called (or when it is never called) are no-ops.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	exporter, err := otlpmetricgrpc.New(ctx,
+		otlpmetricgrpc.WithEndpoint(cfg.ExporterEndpoint), otlpmetricgrpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceNameKey.String(cfg.ServiceName),
+			attribute.String("application", cfg.ServiceName),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(cfg.ExportInterval))),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetMeterProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// meter returns the service meter from the global provider. It is looked up on every call so that
+// instruments bind to whichever provider is registered at the time they are used.
+func meter() metric.Meter {
+	return otel.Meter(meterName)
+}
+
+// RecordProxyRetry counts a retried request to an upstream, labelled with the reason for the retry
+func RecordProxyRetry(ctx context.Context, upstream, reason string) {
+	counter, err := meter().Int64Counter("proxy.upstream.retries",
+		metric.WithDescription("Number of retried requests to a proxied upstream"))
+	if err != nil {
+		return
+	}
+	counter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("upstream", upstream),
+		attribute.String("reason", reason),
+	))
+}
+
+// RecordProxyRetryBudgetExhausted counts retries that were skipped because the retry budget was spent
+func RecordProxyRetryBudgetExhausted(ctx context.Context, upstream string) {
+	counter, err := meter().Int64Counter("proxy.upstream.retry_budget_exhausted",
+		metric.WithDescription("Number of retries skipped because the upstream retry budget was exhausted"))
+	if err != nil {
+		return
+	}
+	counter.Add(ctx, 1, metric.WithAttributes(attribute.String("upstream", upstream)))
+}
+
+// RecordCacheLookup counts a response cache lookup, labelled with its result (HIT, STALE or MISS)
+func RecordCacheLookup(ctx context.Context, result string) {
+	counter, err := meter().Int64Counter("proxy.cache.lookups",
+		metric.WithDescription("Number of response cache lookups by result"))
+	if err != nil {
+		return
+	}
+	counter.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
+
+// RecordProxyCoalesced counts a request that was answered with the response to a concurrent identical request
+// instead of making its own upstream fetch
+func RecordProxyCoalesced(ctx context.Context, upstream string) {
+	counter, err := meter().Int64Counter("proxy.upstream.coalesced_requests",
+		metric.WithDescription("Number of requests that shared a concurrent identical upstream fetch"))
+	if err != nil {
+		return
+	}
+	counter.Add(ctx, 1, metric.WithAttributes(attribute.String("upstream", upstream)))
+}
+
+// RecordRateLimited counts a request that was rejected for exceeding a rate limit, labelled with the scope of
+// the limit (client or route)
+func RecordRateLimited(ctx context.Context, scope string) {
+	counter, err := meter().Int64Counter("proxy.rate_limited_requests",
+		metric.WithDescription("Number of requests rejected for exceeding a rate limit"))
+	if err != nil {
+		return
+	}
+	counter.Add(ctx, 1, metric.WithAttributes(attribute.String("scope", scope)))
+}
+
+// ObserveRedisPool reports the stats of the Redis connection pool returned by stats whenever metrics are collected.
+// Nothing is reported while stats returns nil, e.g. before Redis has been connected to.
+func ObserveRedisPool(stats func() *redis.PoolStats) error {
+	m := meter()
+	connections, err := m.Int64ObservableGauge("proxy.redis.pool.connections",
+		metric.WithDescription("Number of connections in the Redis connection pool, by state (idle or used)"))
+	if err != nil {
+		return err
+	}
+	hits, err := m.Int64ObservableCounter("proxy.redis.pool.hits",
+		metric.WithDescription("Number of times an idle connection was found in the Redis connection pool"))
+	if err != nil {
+		return err
+	}
+	misses, err := m.Int64ObservableCounter("proxy.redis.pool.misses",
+		metric.WithDescription("Number of times no idle connection was found in the Redis connection pool"))
+	if err != nil {
+		return err
+	}
+	timeouts, err := m.Int64ObservableCounter("proxy.redis.pool.timeouts",
+		metric.WithDescription("Number of times waiting for a connection from the Redis connection pool timed out"))
+	if err != nil {
+		return err
+	}
+	stale, err := m.Int64ObservableCounter("proxy.redis.pool.stale_connections",
+		metric.WithDescription("Number of stale connections removed from the Redis connection pool"))
+	if err != nil {
+		return err
+	}
+
+	_, err = m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := stats()
+		if s == nil {
+			return nil
+		}
+		o.ObserveInt64(connections, int64(s.IdleConns), metric.WithAttributes(attribute.String("state", "idle")))
+		o.ObserveInt64(connections, int64(s.TotalConns)-int64(s.IdleConns), metric.WithAttributes(attribute.String("state", "used")))
+		o.ObserveInt64(hits, int64(s.Hits))
+		o.ObserveInt64(misses, int64(s.Misses))
+		o.ObserveInt64(timeouts, int64(s.Timeouts))
+		o.ObserveInt64(stale, int64(s.StaleConns))
+		return nil
+	}, connections, hits, misses, timeouts, stale)
+	return err
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address of the client that made the request. With no trusted proxies in front this is
+// the connection's remote address. Otherwise it is the X-Forwarded-For entry added by the outermost trusted
+// proxy, as the entries to its left were sent by the client and can't be trusted. trustedHops is the number
+// of proxies in front of this one that append to X-Forwarded-For.
+func ClientIP(req *http.Request, trustedHops int) string {
+	if trustedHops > 0 {
+		var hops []string
+		for _, value := range req.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			return hops[max(len(hops)-trustedHops, 0)]
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
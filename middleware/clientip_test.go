@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClientIP(t *testing.T) {
+	Convey("Given a request through two proxies", t, func() {
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.RemoteAddr = "192.168.0.1:1234"
+		req.Header.Add("X-Forwarded-For", "6.6.6.6, 10.0.0.1")
+		req.Header.Add("X-Forwarded-For", "172.16.0.1")
+
+		Convey("Then the client IP depends on the number of trusted hops", func() {
+			So(ClientIP(req, 0), ShouldEqual, "192.168.0.1")
+			So(ClientIP(req, 1), ShouldEqual, "172.16.0.1")
+			So(ClientIP(req, 2), ShouldEqual, "10.0.0.1")
+			So(ClientIP(req, 5), ShouldEqual, "6.6.6.6")
+		})
+	})
+}
@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+)
+
+// Content codings the proxy can apply, in order of preference when the client accepts both equally
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// brotliLevel trades some compression ratio for speed, as responses are compressed on every request
+const brotliLevel = 4
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// Compress returns a middleware that compresses responses with brotli or gzip when the client accepts it.
+// Only responses with one of the given content types and a body of at least minSize bytes are compressed,
+// and responses that are already encoded (e.g. compressed by the upstream) are left alone. Responses that
+// could have been compressed get "Vary: Accept-Encoding", whether or not this request's were.
+func Compress(minSize int, contentTypes []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodHead {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			cw := &compressResponseWriter{
+				ResponseWriter: w,
+				encoding:       negotiateEncoding(req.Header.Values("Accept-Encoding")),
+				minSize:        minSize,
+				contentTypes:   contentTypes,
+			}
+			next.ServeHTTP(cw, req)
+			cw.close()
+		})
+	}
+}
+
+// negotiateEncoding returns the preferred content coding from the Accept-Encoding header values, or "" if
+// the client doesn't accept any coding the proxy can apply
+func negotiateEncoding(acceptEncoding []string) string {
+	qualities := map[string]float64{}
+	for _, value := range acceptEncoding {
+		for _, part := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			q := 1.0
+			if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					continue
+				}
+				q = parsed
+			}
+			qualities[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{encodingBrotli, encodingGzip} {
+		q, ok := qualities[coding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressResponseWriter decides whether to compress a response once its headers are written. If the
+// response has no Content-Length, the start of the body is buffered until it reaches the size threshold.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding     string
+	minSize      int
+	contentTypes []string
+
+	statusCode  int
+	wroteHeader bool
+	pending     bool
+	buf         []byte
+	compressor  io.WriteCloser
+}
+
+func (cw *compressResponseWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	if statusCode < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	cw.wroteHeader = true
+	cw.statusCode = statusCode
+
+	header := cw.Header()
+	if !cw.compressible(statusCode, header) {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	addVary(header, "Accept-Encoding")
+
+	if cw.encoding == "" {
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if contentLength := header.Get("Content-Length"); contentLength != "" {
+		if n, err := strconv.Atoi(contentLength); err == nil && n < cw.minSize {
+			cw.ResponseWriter.WriteHeader(statusCode)
+			return
+		}
+		cw.startCompression()
+		return
+	}
+	cw.pending = true
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case cw.pending:
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) >= cw.minSize {
+			cw.startCompression()
+			if err := cw.writeBuffered(); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	case cw.compressor != nil:
+		return cw.compressor.Write(b)
+	default:
+		return cw.ResponseWriter.Write(b)
+	}
+}
+
+// Flush implements http.Flusher. A streamed response is compressed even if it hasn't reached the size
+// threshold yet, as the rest of its size isn't known.
+func (cw *compressResponseWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.pending {
+		cw.startCompression()
+		_ = cw.writeBuffered()
+	}
+	if flusher, ok := cw.compressor.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressible reports whether the response is one that should be compressed for clients that accept it
+func (cw *compressResponseWriter) compressible(statusCode int, header http.Header) bool {
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	if strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return slices.Contains(cw.contentTypes, mediaType)
+}
+
+// startCompression writes the headers for a compressed response and sets up the compressor
+func (cw *compressResponseWriter) startCompression() {
+	cw.pending = false
+
+	header := cw.Header()
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	header.Set("Content-Encoding", cw.encoding)
+	// the compressed body is a different representation, so a strong validator no longer applies
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+
+	switch cw.encoding {
+	case encodingBrotli:
+		cw.compressor = brotli.NewWriterLevel(cw.ResponseWriter, brotliLevel)
+	case encodingGzip:
+		gz := gzipWriters.Get().(*gzip.Writer)
+		gz.Reset(cw.ResponseWriter)
+		cw.compressor = gz
+	}
+}
+
+func (cw *compressResponseWriter) writeBuffered() error {
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.compressor.Write(buf)
+	return err
+}
+
+// close finishes the response. A buffered response that never reached the size threshold is written
+// uncompressed.
+func (cw *compressResponseWriter) close() {
+	if cw.pending {
+		cw.pending = false
+		cw.ResponseWriter.WriteHeader(cw.statusCode)
+		_, _ = cw.ResponseWriter.Write(cw.buf)
+		return
+	}
+	if cw.compressor == nil {
+		return
+	}
+	_ = cw.compressor.Close()
+	if gz, ok := cw.compressor.(*gzip.Writer); ok {
+		gz.Reset(io.Discard)
+		gzipWriters.Put(gz)
+	}
+}
+
+// addVary adds name to the Vary header unless it is already listed
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			existing = strings.TrimSpace(existing)
+			if existing == "*" || strings.EqualFold(existing, name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
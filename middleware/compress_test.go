@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompress(t *testing.T) {
+	Convey("Given a handler wrapped in the compression middleware", t, func() {
+		body := strings.Repeat("<p>Census 2021</p>", 100)
+		var (
+			contentType     = "text/html; charset=utf-8"
+			contentEncoding string
+			contentLength   string
+		)
+		handler := Compress(1024, []string{"text/html"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("ETag", `"abc"`)
+			if contentEncoding != "" {
+				w.Header().Set("Content-Encoding", contentEncoding)
+			}
+			if contentLength != "" {
+				w.Header().Set("Content-Length", contentLength)
+			}
+			_, _ = w.Write([]byte(body))
+		}))
+
+		serve := func(acceptEncoding string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/census", http.NoBody)
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When the client accepts gzip", func() {
+			w := serve("gzip, deflate")
+
+			Convey("Then the response is gzipped", func() {
+				So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+				So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+				So(w.Header().Get("ETag"), ShouldEqual, `W/"abc"`)
+				reader, err := gzip.NewReader(w.Body)
+				So(err, ShouldBeNil)
+				decoded, err := io.ReadAll(reader)
+				So(err, ShouldBeNil)
+				So(string(decoded), ShouldEqual, body)
+			})
+		})
+
+		Convey("When the client accepts brotli and gzip", func() {
+			w := serve("gzip, br")
+
+			Convey("Then the response is compressed with brotli", func() {
+				So(w.Header().Get("Content-Encoding"), ShouldEqual, "br")
+				decoded, err := io.ReadAll(brotli.NewReader(w.Body))
+				So(err, ShouldBeNil)
+				So(string(decoded), ShouldEqual, body)
+			})
+		})
+
+		Convey("When the client prefers gzip by quality", func() {
+			w := serve("br;q=0.5, gzip")
+
+			Convey("Then the response is gzipped", func() {
+				So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+			})
+		})
+
+		Convey("When the client doesn't accept compression", func() {
+			w := serve("")
+
+			Convey("Then the response is not compressed but still varies on Accept-Encoding", func() {
+				So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+				So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+				So(w.Body.String(), ShouldEqual, body)
+			})
+		})
+
+		Convey("When the response is below the size threshold", func() {
+			body = "<p>small</p>"
+			w := serve("gzip")
+
+			Convey("Then it is not compressed", func() {
+				So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+				So(w.Body.String(), ShouldEqual, body)
+			})
+		})
+
+		Convey("When the response has a Content-Length below the size threshold", func() {
+			body = "<p>small</p>"
+			contentLength = "12"
+			w := serve("gzip")
+
+			Convey("Then it is not compressed", func() {
+				So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+				So(w.Header().Get("Content-Length"), ShouldEqual, "12")
+				So(w.Body.String(), ShouldEqual, body)
+			})
+		})
+
+		Convey("When the response is already compressed by the upstream", func() {
+			contentEncoding = "gzip"
+			w := serve("gzip, br")
+
+			Convey("Then it is not compressed again", func() {
+				So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+				So(w.Body.String(), ShouldEqual, body)
+			})
+		})
+
+		Convey("When the response content type isn't compressible", func() {
+			contentType = "image/png"
+			w := serve("gzip")
+
+			Convey("Then it is not compressed and doesn't vary on Accept-Encoding", func() {
+				So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+				So(w.Header().Get("Vary"), ShouldBeEmpty)
+				So(w.Body.String(), ShouldEqual, body)
+			})
+		})
+	})
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	Convey("Accept-Encoding values are negotiated to the preferred supported coding", t, func() {
+		So(negotiateEncoding(nil), ShouldBeEmpty)
+		So(negotiateEncoding([]string{"identity"}), ShouldBeEmpty)
+		So(negotiateEncoding([]string{"gzip"}), ShouldEqual, "gzip")
+		So(negotiateEncoding([]string{"gzip", "br"}), ShouldEqual, "br")
+		So(negotiateEncoding([]string{"br;q=0, gzip;q=0.1"}), ShouldEqual, "gzip")
+		So(negotiateEncoding([]string{"*"}), ShouldEqual, "br")
+		So(negotiateEncoding([]string{"*;q=0"}), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// requestIDLength is the length of generated request IDs when there is no trace to link to
+	requestIDLength = 16
+	// maxRequestIDLength is the longest incoming request ID that will be accepted
+	maxRequestIDLength = 128
+)
+
+// RequestID returns a middleware that makes sure every request has an X-Request-Id. An incoming ID is kept
+// if it is valid, otherwise one is generated, using the OpenTelemetry trace ID when there is an active trace.
+// The ID is added to the request context (so it appears in logs), forwarded to upstreams in the request
+// headers, recorded on the trace span and echoed in the response, including redirects.
+func RequestID() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			span := trace.SpanFromContext(ctx)
+
+			requestID := req.Header.Get(request.RequestHeaderKey)
+			if !isValidRequestID(requestID) {
+				requestID = newRequestID(span.SpanContext())
+				req.Header.Set(request.RequestHeaderKey, requestID)
+			}
+
+			span.SetAttributes(attribute.String("request_id", requestID))
+
+			req = req.WithContext(request.WithRequestId(ctx, requestID))
+			next.ServeHTTP(&requestIDResponseWriter{ResponseWriter: w, requestID: requestID}, req)
+		})
+	}
+}
+
+// newRequestID creates a request ID, reusing the trace ID if there is one so that the two can be correlated
+func newRequestID(spanContext trace.SpanContext) string {
+	if spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+	return request.NewRequestID(requestIDLength)
+}
+
+// isValidRequestID reports whether id is non-empty, not too long and only contains visible ASCII characters
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDResponseWriter sets the X-Request-Id response header when the response is written, replacing
+// any value copied from an upstream response
+type requestIDResponseWriter struct {
+	http.ResponseWriter
+	requestID   string
+	wroteHeader bool
+}
+
+func (w *requestIDResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set(request.RequestHeaderKey, w.requestID)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *requestIDResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying ResponseWriter, e.g. to flush
+func (w *requestIDResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
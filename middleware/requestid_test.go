@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/dp-net/v3/request"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestID(t *testing.T) {
+	Convey("Given a handler wrapped in the request ID middleware", t, func() {
+		var (
+			contextID string
+			headerID  string
+		)
+		handler := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contextID = request.GetRequestId(r.Context())
+			headerID = r.Header.Get(request.RequestHeaderKey)
+			w.Header().Set(request.RequestHeaderKey, "upstream-id")
+			http.Redirect(w, r, "/new-url", http.StatusPermanentRedirect)
+		}))
+
+		Convey("When a request arrives with an X-Request-Id", func() {
+			req := httptest.NewRequest(http.MethodGet, "/old-url", http.NoBody)
+			req.Header.Set(request.RequestHeaderKey, "abc123")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			Convey("Then the ID is kept, put into the context and echoed in the redirect response", func() {
+				So(contextID, ShouldEqual, "abc123")
+				So(headerID, ShouldEqual, "abc123")
+				So(w.Code, ShouldEqual, http.StatusPermanentRedirect)
+				So(w.Header().Values(request.RequestHeaderKey), ShouldResemble, []string{"abc123"})
+			})
+		})
+
+		Convey("When a request arrives without an X-Request-Id", func() {
+			req := httptest.NewRequest(http.MethodGet, "/old-url", http.NoBody)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			Convey("Then a new ID is generated and used throughout", func() {
+				So(contextID, ShouldHaveLength, requestIDLength)
+				So(headerID, ShouldEqual, contextID)
+				So(w.Header().Get(request.RequestHeaderKey), ShouldEqual, contextID)
+			})
+		})
+
+		Convey("When a request arrives with an invalid X-Request-Id", func() {
+			req := httptest.NewRequest(http.MethodGet, "/old-url", http.NoBody)
+			req.Header.Set(request.RequestHeaderKey, strings.Repeat("a", maxRequestIDLength+1))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			Convey("Then it is replaced with a generated ID", func() {
+				So(contextID, ShouldHaveLength, requestIDLength)
+			})
+		})
+
+		Convey("When a request without an X-Request-Id is part of a trace", func() {
+			traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+			So(err, ShouldBeNil)
+			spanContext := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID})
+			ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+			req := httptest.NewRequest(http.MethodGet, "/old-url", http.NoBody).WithContext(ctx)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			Convey("Then the trace ID is used as the request ID", func() {
+				So(contextID, ShouldEqual, "4bf92f3577b34da6a3ce929d0e0e4736")
+				So(w.Header().Get(request.RequestHeaderKey), ShouldEqual, "4bf92f3577b34da6a3ce929d0e0e4736")
+			})
+		})
+	})
+}
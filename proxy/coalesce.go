@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ONSdigital/dis-redirect-proxy/cache"
+	"github.com/ONSdigital/dis-redirect-proxy/metrics"
+	"github.com/ONSdigital/log.go/v2/log"
+	"golang.org/x/sync/singleflight"
+)
+
+// coalescer lets concurrent identical GET requests share a single upstream fetch. The first request (the
+// leader) is proxied as normal while its response is captured; requests that arrive while it is in flight
+// wait for it and are sent a copy of its response, unless their client goes away first. The leader's fetch
+// isn't cancelled if its client goes away, as the followers still need the response. A follower fetches for
+// itself instead if the response can't be shared: it was too large to capture, was private, set a cookie, or
+// varies on a header whose value differs between the follower's and the leader's requests.
+type coalescer struct {
+	group       singleflight.Group
+	authCookies []string
+	maxBodySize int64
+}
+
+// sharedResponse is a leader's response, captured so that it can be sent to followers
+type sharedResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	vary       map[string]string
+}
+
+func newCoalescer(authCookies []string, maxBodySize int64) *coalescer {
+	return &coalescer{
+		authCookies: authCookies,
+		maxBodySize: maxBodySize,
+	}
+}
+
+// Handler returns a handler that coalesces concurrent identical requests to next, which proxies to upstream
+func (c *coalescer) Handler(upstream string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !c.canCoalesce(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		ctx := req.Context()
+		key := upstream + "#" + req.Host + "#" + req.URL.RequestURI()
+
+		// mu is held by the leader's fetch, so that the handler doesn't return while the fetch is writing to w
+		var (
+			mu       sync.Mutex
+			leader   bool
+			returned bool
+			abort    interface{}
+		)
+		results := c.group.DoChan(key, func() (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			leader = true
+			rw := w
+			if returned {
+				// the client went away before the fetch started, but the followers still need the response
+				rw = &cache.DiscardWriter{}
+			}
+			cw := cache.NewCaptureWriter(rw, c.maxBodySize, nil)
+			defer func() {
+				// ReverseProxy panics with http.ErrAbortHandler if the upstream response can't be copied; keep
+				// the panic for the leader's own connection rather than passing it on to the followers
+				abort = recover()
+			}()
+			next.ServeHTTP(cw, req.WithContext(context.WithoutCancel(ctx)))
+			return newSharedResponse(cw, req), nil
+		})
+
+		var value interface{}
+		select {
+		case result := <-results:
+			value = result.Val
+		case <-ctx.Done():
+			// a follower stops waiting once its client has gone away, while a leader waits for its fetch to finish
+			mu.Lock()
+			returned = true
+			fetched := leader
+			mu.Unlock()
+			if !fetched {
+				return
+			}
+		}
+
+		if leader {
+			if abort != nil {
+				panic(abort)
+			}
+			return
+		}
+
+		shared, _ := value.(*sharedResponse)
+		if shared == nil || !shared.matches(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		metrics.RecordProxyCoalesced(ctx, upstream)
+		cache.CopyHeader(w.Header(), shared.header.Clone())
+		w.WriteHeader(shared.statusCode)
+		if _, err := w.Write(shared.body); err != nil {
+			log.Error(ctx, "error writing coalesced response", err, log.Data{"upstream": upstream})
+		}
+	})
+}
+
+// canCoalesce reports whether a request may share its upstream fetch. Requests that could get a response
+// specific to the user, or to the part of the resource they asked for, are never coalesced.
+func (c *coalescer) canCoalesce(req *http.Request) bool {
+	if req.Method != http.MethodGet || req.ContentLength > 0 {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" || req.Header.Get("Range") != "" {
+		return false
+	}
+	for _, name := range c.authCookies {
+		if _, err := req.Cookie(name); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the response can be sent in reply to req, i.e. req has the same values as the
+// leader's request for every header the response varies on
+func (s *sharedResponse) matches(req *http.Request) bool {
+	for name, value := range s.vary {
+		if strings.Join(req.Header.Values(name), ",") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// newSharedResponse returns the response captured by cw in reply to req, or nil if it must not be shared with
+// other requests
+func newSharedResponse(cw *cache.CaptureWriter, req *http.Request) *sharedResponse {
+	body, complete := cw.Body()
+	if !complete {
+		return nil
+	}
+	header := cw.Header()
+	if header.Get("Set-Cookie") != "" {
+		return nil
+	}
+	cacheControl := strings.ToLower(strings.Join(header.Values("Cache-Control"), ","))
+	if strings.Contains(cacheControl, "private") || strings.Contains(cacheControl, "no-store") {
+		return nil
+	}
+
+	vary := map[string]string{}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil
+			}
+			if name != "" {
+				vary[name] = strings.Join(req.Header.Values(name), ",")
+			}
+		}
+	}
+
+	return &sharedResponse{
+		statusCode: cw.StatusCode(),
+		header:     header.Clone(),
+		body:       bytes.Clone(body),
+		vary:       vary,
+	}
+}
@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCoalescer(t *testing.T) {
+	Convey("Given a coalescing handler in front of a slow upstream", t, func() {
+		var calls atomic.Int32
+		release := make(chan struct{})
+		cacheControl := "public, max-age=60"
+		upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			<-release
+			if r.Context().Err() != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Header().Set("Cache-Control", cacheControl)
+			w.Header().Set("Vary", "Accept-Language")
+			_, _ = w.Write([]byte("body for " + r.Header.Get("Accept-Language")))
+		})
+		handler := newCoalescer([]string{"access_token"}, 1024).Handler(upstreamLegacy, upstream)
+
+		// serve sends the leader request, waits for it to reach the upstream, then sends the followers while the
+		// leader is still in flight
+		serve := func(leader *http.Request, followers ...*http.Request) []*httptest.ResponseRecorder {
+			recorders := make([]*httptest.ResponseRecorder, len(followers)+1)
+			var wg sync.WaitGroup
+			for i, req := range append([]*http.Request{leader}, followers...) {
+				recorders[i] = httptest.NewRecorder()
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					handler.ServeHTTP(recorders[i], req)
+				}()
+				if i == 0 {
+					for calls.Load() == 0 {
+						time.Sleep(time.Millisecond)
+					}
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			return recorders
+		}
+
+		newRequest := func(language string) *http.Request {
+			req := httptest.NewRequest(http.MethodGet, "/releases/census", http.NoBody)
+			req.Header.Set("Accept-Language", language)
+			return req
+		}
+
+		Convey("When identical requests arrive at the same time", func() {
+			recorders := serve(newRequest("en"), newRequest("en"), newRequest("en"))
+
+			Convey("Then they share one upstream fetch", func() {
+				So(calls.Load(), ShouldEqual, 1)
+				for _, w := range recorders {
+					So(w.Code, ShouldEqual, http.StatusOK)
+					So(w.Body.String(), ShouldEqual, "body for en")
+					So(w.Header().Get("Cache-Control"), ShouldEqual, cacheControl)
+				}
+			})
+		})
+
+		Convey("When the client of the first request goes away while the others wait for it", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			recorders := serve(newRequest("en").WithContext(ctx), newRequest("en"))
+
+			Convey("Then the shared fetch isn't cancelled", func() {
+				So(calls.Load(), ShouldEqual, 1)
+				So(recorders[1].Code, ShouldEqual, http.StatusOK)
+				So(recorders[1].Body.String(), ShouldEqual, "body for en")
+			})
+		})
+
+		Convey("When the client of a waiting request goes away", func() {
+			leaderDone := make(chan struct{})
+			leader := httptest.NewRecorder()
+			go func() {
+				defer close(leaderDone)
+				handler.ServeHTTP(leader, newRequest("en"))
+			}()
+			for calls.Load() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			followerDone := make(chan struct{})
+			go func() {
+				defer close(followerDone)
+				handler.ServeHTTP(httptest.NewRecorder(), newRequest("en").WithContext(ctx))
+			}()
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+
+			Convey("Then it stops waiting without the first request's fetch finishing", func() {
+				var returned bool
+				select {
+				case <-followerDone:
+					returned = true
+				case <-time.After(time.Second):
+				}
+				So(returned, ShouldBeTrue)
+
+				close(release)
+				<-leaderDone
+				So(calls.Load(), ShouldEqual, 1)
+				So(leader.Code, ShouldEqual, http.StatusOK)
+				So(leader.Body.String(), ShouldEqual, "body for en")
+			})
+		})
+
+		Convey("When a concurrent request has a different value for a Vary header", func() {
+			recorders := serve(newRequest("en"), newRequest("cy"))
+
+			Convey("Then it fetches its own response", func() {
+				So(calls.Load(), ShouldEqual, 2)
+				So(recorders[1].Body.String(), ShouldEqual, "body for cy")
+			})
+		})
+
+		Convey("When a concurrent request has an auth cookie", func() {
+			authorised := newRequest("en")
+			authorised.AddCookie(&http.Cookie{Name: "access_token", Value: "token"})
+			serve(newRequest("en"), authorised)
+
+			Convey("Then it is not coalesced", func() {
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When a concurrent request has an Authorization header", func() {
+			authorised := newRequest("en")
+			authorised.Header.Set("Authorization", "Bearer token")
+			serve(newRequest("en"), authorised)
+
+			Convey("Then it is not coalesced", func() {
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the upstream response is private", func() {
+			cacheControl = "private, max-age=60"
+			recorders := serve(newRequest("en"), newRequest("en"))
+
+			Convey("Then it is not shared", func() {
+				So(calls.Load(), ShouldEqual, 2)
+				So(recorders[1].Body.String(), ShouldEqual, "body for en")
+			})
+		})
+	})
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Kinds of upstream error, used in logs
+const (
+	errKindCanceled          = "client_canceled"
+	errKindConnectionRefused = "connection_refused"
+	errKindDNS               = "dns"
+	errKindTimeout           = "timeout"
+	errKindTLS               = "tls"
+	errKindUnknown           = "unknown"
+)
+
+// classifyError works out what kind of failure occurred when contacting an upstream and the status
+// code that should be returned to the client
+func classifyError(err error) (kind string, statusCode int) {
+	var (
+		dnsErr       *net.DNSError
+		recordErr    tls.RecordHeaderError
+		verifyErr    *tls.CertificateVerificationError
+		alertErr     tls.AlertError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		netErr       net.Error
+	)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errKindCanceled, http.StatusBadGateway
+	case errors.As(err, &dnsErr):
+		return errKindDNS, http.StatusBadGateway
+	case errors.As(err, &recordErr), errors.As(err, &verifyErr), errors.As(err, &alertErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return errKindTLS, http.StatusBadGateway
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errKindConnectionRefused, http.StatusBadGateway
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errKindTimeout, http.StatusGatewayTimeout
+	default:
+		return errKindUnknown, http.StatusBadGateway
+	}
+}
+
+// errorHandler returns a ReverseProxy ErrorHandler that logs the upstream failure and renders an error page
+func (proxy *Proxy) errorHandler(upstream string) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, req *http.Request, err error) {
+		ctx := req.Context()
+		kind, statusCode := classifyError(err)
+		logData := log.Data{
+			"upstream":    upstream,
+			"error_kind":  kind,
+			"method":      req.Method,
+			"request_url": req.URL.String(),
+			"status_code": statusCode,
+		}
+
+		if kind == errKindCanceled {
+			// the client has gone away, so there is nobody to send an error page to
+			log.Info(ctx, "client cancelled request to upstream", logData)
+			w.WriteHeader(statusCode)
+			return
+		}
+
+		log.Error(ctx, "error proxying request to upstream", err, logData)
+		proxy.errorPage.Write(ctx, w, req, statusCode, errorMessage(statusCode))
+	}
+}
+
+func errorMessage(statusCode int) string {
+	if statusCode == http.StatusGatewayTimeout {
+		return "The service took too long to respond."
+	}
+	return "The service is temporarily unavailable."
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClassifyError(t *testing.T) {
+	Convey("Given a set of upstream errors", t, func() {
+		cases := []struct {
+			err        error
+			kind       string
+			statusCode int
+		}{
+			{&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "legacy"}}, errKindDNS, http.StatusBadGateway},
+			{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, errKindConnectionRefused, http.StatusBadGateway},
+			{fmt.Errorf("tls: %w", x509.UnknownAuthorityError{}), errKindTLS, http.StatusBadGateway},
+			{&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, errKindTimeout, http.StatusGatewayTimeout},
+			{context.DeadlineExceeded, errKindTimeout, http.StatusGatewayTimeout},
+			{context.Canceled, errKindCanceled, http.StatusBadGateway},
+			{errors.New("something else"), errKindUnknown, http.StatusBadGateway},
+		}
+
+		Convey("Then each is classified with the expected kind and status code", func() {
+			for _, c := range cases {
+				kind, statusCode := classifyError(c.err)
+				So(kind, ShouldEqual, c.kind)
+				So(statusCode, ShouldEqual, c.statusCode)
+			}
+		})
+	})
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dp-net/v3/request"
+)
+
+// generatedHeaderLength is the length of values generated for headers listed in HeaderRule.GenerateIfMissing
+const generatedHeaderLength = 16
+
+// applyHeaderRule modifies the outbound request headers according to rule. Headers are removed first, then
+// renamed, then generated if missing, and finally added and set.
+func applyHeaderRule(rule *config.HeaderRule, header http.Header) {
+	for _, name := range rule.Remove {
+		header.Del(name)
+	}
+
+	for from, to := range rule.Rename {
+		values := header.Values(from)
+		if len(values) == 0 {
+			continue
+		}
+		header.Del(from)
+		header.Del(to)
+		for _, value := range values {
+			header.Add(to, value)
+		}
+	}
+
+	for _, name := range rule.GenerateIfMissing {
+		if header.Get(name) == "" {
+			header.Set(name, request.NewRequestID(generatedHeaderLength))
+		}
+	}
+
+	for name, value := range rule.Add {
+		header.Add(name, value)
+	}
+
+	for name, value := range rule.Set {
+		header.Set(name, value)
+	}
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestApplyHeaderRule(t *testing.T) {
+	Convey("Given a header rule that uses every kind of manipulation", t, func() {
+		rule := &config.HeaderRule{
+			Remove:            []string{"Cookie"},
+			Rename:            map[string]string{"X-Old-Name": "X-New-Name"},
+			GenerateIfMissing: []string{"X-Request-Id"},
+			Add:               map[string]string{"Via": "dis-redirect-proxy"},
+			Set:               map[string]string{"Authorization": "Bearer token"},
+		}
+
+		Convey("When it is applied to request headers", func() {
+			header := http.Header{
+				"Cookie":        {"session=abc"},
+				"X-Old-Name":    {"value"},
+				"Via":           {"1.1 cdn"},
+				"Authorization": {"Bearer user-token"},
+			}
+			applyHeaderRule(rule, header)
+
+			Convey("Then the headers are modified as described", func() {
+				So(header.Get("Cookie"), ShouldBeEmpty)
+				So(header.Get("X-Old-Name"), ShouldBeEmpty)
+				So(header.Get("X-New-Name"), ShouldEqual, "value")
+				So(header.Get("X-Request-Id"), ShouldHaveLength, generatedHeaderLength)
+				So(header.Values("Via"), ShouldResemble, []string{"1.1 cdn", "dis-redirect-proxy"})
+				So(header.Get("Authorization"), ShouldEqual, "Bearer token")
+			})
+		})
+
+		Convey("When the generated header is already present", func() {
+			header := http.Header{"X-Request-Id": {"existing"}}
+			applyHeaderRule(rule, header)
+
+			Convey("Then it is left unchanged", func() {
+				So(header.Get("X-Request-Id"), ShouldEqual, "existing")
+			})
+		})
+	})
+}
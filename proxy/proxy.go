@@ -2,60 +2,108 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"time"
 
 	disRedis "github.com/ONSdigital/dis-redis"
 
+	"github.com/ONSdigital/dis-redirect-proxy/cache"
 	"github.com/ONSdigital/dis-redirect-proxy/clients"
 	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/flags"
+	"github.com/ONSdigital/dis-redirect-proxy/redirects"
+	"github.com/ONSdigital/dis-redirect-proxy/response"
 	"github.com/ONSdigital/dp-net/v3/http/fallback"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/gorilla/mux"
 )
 
+// Names of the upstream services, used in logs and metrics
+const (
+	upstreamLegacy  = "legacy"
+	upstreamWagtail = "wagtail"
+)
+
 // Proxy provides a struct to wrap the proxy around
 type Proxy struct {
 	Router      *mux.Router
 	RedisClient clients.Redis
+	Cache       *cache.Cache
+	Flags       *flags.Flags
+	cfg         *config.Config
+	coalescer   *coalescer
+	errorPage   *response.ErrorPage
+	headers     *response.HeaderPolicyEngine
 }
 
 // Setup function sets up the proxy and returns a Proxy
 func Setup(ctx context.Context, r *mux.Router, cfg *config.Config, redisCli clients.Redis) (*Proxy, error) {
+	errorPage, err := response.NewErrorPage(cfg.ErrorPageTemplatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// redirects may be enabled at runtime if the feature flags have a dynamic source
+	if (cfg.EnableRedirects || cfg.FeatureFlagsSource != "") && redisCli == nil {
+		return nil, errors.New("redirects require a redis client")
+	}
+
+	featureFlags, err := flags.New(cfg, redisCli)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create feature flags: %w", err)
+	}
+
 	proxy := &Proxy{
 		Router:      r,
 		RedisClient: redisCli,
+		Flags:       featureFlags,
+		cfg:         cfg,
+		errorPage:   errorPage,
+		headers:     response.NewHeaderPolicyEngine(cfg.ResponseHeaderPolicies),
 	}
 
-	// Only create middleware with Redis check if feature flag is enabled
-	if cfg.EnableRedirects {
-		// Middleware for redirect check
-		r.Use(proxy.redirectMiddleware(redisCli))
+	if cfg.ProxyCoalescingEnabled {
+		log.Info(ctx, "enabling coalescing of concurrent identical upstream requests")
+		proxy.coalescer = newCoalescer(cfg.ProxyCoalescingAuthCookies, cfg.ProxyCoalescingMaxBodySize)
 	}
 
+	// Middleware for redirect check, which only checks Redis while the feature flag is enabled
+	r.Use(proxy.redirectMiddleware(redisCli))
+
 	proxiedUrl, err := url.Parse(cfg.ProxiedServiceURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse proxied service url: %w", err)
 	}
 
-	proxyHandler := newReverseProxy(proxiedUrl)
+	proxyHandler := proxy.upstreamHandler(upstreamLegacy, proxiedUrl)
 
-	// If releases fallback is enabled, set up alternative handler
-	if cfg.EnableReleasesFallback {
-		log.Info(ctx, "enabling releases fallback proxy")
-		wagtailProxy, err := url.Parse(cfg.WagtailURL)
+	if cfg.CacheEnabled {
+		log.Info(ctx, "enabling response cache for legacy upstream", log.Data{"store": cfg.CacheStore})
+		proxy.Cache, err = cache.New(cfg, redisCli)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse wagtail proxied service url: %w", err)
+			return nil, fmt.Errorf("failed to create response cache: %w", err)
 		}
-		wagtailProxyHandler := newReverseProxy(wagtailProxy)
+		proxyHandler = proxy.Cache.Handler(proxyHandler)
+	}
 
-		alternativeHandler := fallback.Try(wagtailProxyHandler).WhenStatus(http.StatusNotFound).Then(proxyHandler)
-		r.PathPrefix("/releases/").Name("Release alternative").Handler(alternativeHandler)
+	// Set up the alternative handler for releases, which is only used while the feature flag is enabled
+	wagtailProxy, err := url.Parse(cfg.WagtailURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse wagtail proxied service url: %w", err)
 	}
+	wagtailProxyHandler := proxy.upstreamHandler(upstreamWagtail, wagtailProxy)
+
+	alternativeHandler := fallback.Try(wagtailProxyHandler).WhenStatus(http.StatusNotFound).Then(proxyHandler)
+	r.PathPrefix("/releases/").Name("Release alternative").Handler(
+		proxy.flagged(flags.EnableReleasesFallback, alternativeHandler, proxyHandler))
 
 	r.PathPrefix("/").Name("Proxy Catch-All").Handler(proxyHandler)
+
+	featureFlags.Start(ctx)
 	return proxy, nil
 }
 
@@ -63,8 +111,14 @@ func Setup(ctx context.Context, r *mux.Router, cfg *config.Config, redisCli clie
 func (proxy *Proxy) redirectMiddleware(redisCli clients.Redis) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			// Skip redirect check for health endpoint
-			if req.URL.Path == "/health" {
+			// Skip redirect check for the health endpoint and the liveness and readiness probes, which must not
+			// depend on Redis, or if the feature flag is off
+			switch req.URL.Path {
+			case "/health", "/livez", "/readyz":
+				next.ServeHTTP(w, req)
+				return
+			}
+			if !proxy.Flags.Enabled(flags.EnableRedirects) {
 				next.ServeHTTP(w, req)
 				return
 			}
@@ -90,30 +144,74 @@ func (proxy *Proxy) redirectMiddleware(redisCli clients.Redis) mux.MiddlewareFun
 
 // checkRedirect checks if a redirect exists in Redis
 func (proxy *Proxy) checkRedirect(checkURL string, ctx context.Context, redisClient clients.Redis) (string, error) {
-	// Get the redirect URL from Redis based on the incoming URL path
-	redirectURL, err := redisClient.GetValue(ctx, checkURL)
+	// Get the redirect from Redis based on the incoming URL path
+	value, err := redisClient.GetValue(ctx, redirects.Key(proxy.cfg.RedirectKeyPrefix, checkURL))
 	if err == disRedis.ErrKeyNotFound {
 		// If the key does not exist, return an empty string
 		return "", nil
+	} else if errors.Is(err, clients.ErrRedisNotConnected) {
+		// Redirects are disabled until Redis has been connected to, which is reported by the health check
+		return "", nil
 	} else if err != nil {
 		// If an error occurs while checking Redis, log it and return the error
 		log.Error(ctx, "error checking Redis for redirect", err)
 		return "", err
 	}
 
+	entry, err := redirects.ParseEntry(value)
+	if err != nil {
+		log.Error(ctx, "invalid redirect in Redis", err, log.Data{"path": checkURL})
+		return "", err
+	}
+	// A redirect is ignored outside of its window
+	if entry.Status(time.Now()) != redirects.StatusActive {
+		return "", nil
+	}
+
 	// Return the found redirect URL
-	return redirectURL, nil
+	return entry.To, nil
 }
 
-func newReverseProxy(proxiedUrl *url.URL) *httputil.ReverseProxy {
+// flagged returns a handler that uses enabled while the named feature flag is enabled and disabled otherwise,
+// so that features can be switched without rebuilding the router
+func (proxy *Proxy) flagged(name string, enabled, disabled http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if proxy.Flags.Enabled(name) {
+			enabled.ServeHTTP(w, req)
+			return
+		}
+		disabled.ServeHTTP(w, req)
+	})
+}
+
+// upstreamHandler returns the handler that proxies requests to an upstream, coalescing concurrent identical
+// requests if enabled
+func (proxy *Proxy) upstreamHandler(upstream string, proxiedUrl *url.URL) http.Handler {
+	reverseProxy := proxy.newReverseProxy(upstream, proxiedUrl)
+	if proxy.coalescer == nil {
+		return reverseProxy
+	}
+	return proxy.coalescer.Handler(upstream, reverseProxy)
+}
+
+func (proxy *Proxy) newReverseProxy(upstream string, proxiedUrl *url.URL) *httputil.ReverseProxy {
 	// TODO add end request logging
 	// TODO consider other proxy options eg. timeouts, proxy-from-env etc. (see dp-frontend-router main.go for similar)
+	budget := NewRetryBudget(proxy.cfg.ProxyRetryBudgetRatio, proxy.cfg.ProxyRetryBudgetMinPerSec)
+	headerRule, hasHeaderRule := proxy.cfg.UpstreamHeaderRules[upstream]
+
 	return &httputil.ReverseProxy{
+		Transport:      newRetryTransport(http.DefaultTransport, upstream, NewRetryPolicy(proxy.cfg), budget),
+		ErrorHandler:   proxy.errorHandler(upstream),
+		ModifyResponse: proxy.headers.ModifyResponse,
 		Rewrite: func(req *httputil.ProxyRequest) {
 			log.Info(req.In.Context(), "forwarding request to target", log.Data{"request_url": req.In.URL.String(), "target": proxiedUrl.String()})
 			req.SetURL(proxiedUrl)
 			req.Out.Host = req.In.Host
 			req.SetXForwarded()
+			if hasHeaderRule {
+				applyHeaderRule(&headerRule, req.Out.Header)
+			}
 		},
 	}
 }
@@ -6,7 +6,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	clientMocks "github.com/ONSdigital/dis-redirect-proxy/clients/mock"
 	"github.com/ONSdigital/dis-redirect-proxy/config"
@@ -39,6 +42,15 @@ func TestSetup(t *testing.T) {
 			So(hasRoute(redirectProxy.Router, "/", http.MethodPatch), ShouldBeTrue)
 		})
 	})
+
+	Convey("Given redirects are enabled but there is no Redis client", t, func() {
+		cfg := &config.Config{EnableRedirects: true}
+
+		Convey("Then the proxy can't be set up", func() {
+			_, err := proxy.Setup(context.Background(), mux.NewRouter(), cfg, nil)
+			So(err, ShouldBeError, "redirects require a redis client")
+		})
+	})
 }
 
 func TestProxyHandleRequestWithRedirect(t *testing.T) {
@@ -49,6 +61,12 @@ func TestProxyHandleRequestWithRedirect(t *testing.T) {
 				switch key {
 				case "/old-url":
 					return "http://localhost:8081/new-url", nil
+				case "/scheduled-url":
+					return `{"to":"http://localhost:8081/new-url","not_before":"2000-01-01T00:00:00Z","not_after":"2999-01-01T00:00:00Z"}`, nil
+				case "/upcoming-url":
+					return `{"to":"http://localhost:8081/new-url","not_before":"2999-01-01T00:00:00Z"}`, nil
+				case "/expired-url":
+					return `{"to":"http://localhost:8081/new-url","not_after":"2000-01-01T00:00:00Z"}`, nil
 				case nonRedirectURL:
 					return "", disRedis.ErrKeyNotFound
 				case "/health":
@@ -134,6 +152,24 @@ func TestProxyHandleRequestWithRedirect(t *testing.T) {
 					So(calls[len(calls)-1].Key, ShouldEqual, "/old-url")
 				})
 
+				Convey("When a request is within the window of a redirect", func() {
+					rr := httptest.NewRecorder()
+					redirectProxy.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/scheduled-url", http.NoBody))
+
+					So(rr.Code, ShouldEqual, http.StatusPermanentRedirect)
+					So(rr.Header().Get("Location"), ShouldEqual, "http://localhost:8081/new-url")
+				})
+
+				Convey("When a request is outside the window of a redirect", func() {
+					for _, path := range []string{"/upcoming-url", "/expired-url"} {
+						rr := httptest.NewRecorder()
+						redirectProxy.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+
+						// The request is proxied instead, and the mock server doesn't know the path
+						So(rr.Code, ShouldEqual, http.StatusNotFound)
+					}
+				})
+
 				Convey("When a request does not trigger a redirect", func() {
 					req, err := http.NewRequest("GET", nonRedirectURL, http.NoBody)
 					So(err, ShouldBeNil)
@@ -168,6 +204,33 @@ func TestProxyHandleRequestWithRedirect(t *testing.T) {
 					// Assert that Redis was not contacted for /health
 					So(redisClientMock.GetValueCalls(), ShouldBeEmpty)
 				})
+
+				Convey("When the liveness and readiness probes are requested, Redis should not be contacted", func() {
+					for _, path := range []string{"/livez", "/readyz"} {
+						rr := httptest.NewRecorder()
+						redirectProxy.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+					}
+
+					So(redisClientMock.GetValueCalls(), ShouldBeEmpty)
+				})
+			})
+		})
+
+		Convey("When redirects are stored under a key prefix", func() {
+			cfg := &config.Config{
+				EnableRedirects:   true,
+				ProxiedServiceURL: mockServer.URL,
+				RedirectKeyPrefix: "redirect:",
+			}
+			redirectProxy, err := proxy.Setup(context.Background(), mux.NewRouter(), cfg, redisClientMock)
+			So(err, ShouldBeNil)
+
+			Convey("Then Redis is checked for the path under the prefix", func() {
+				req := httptest.NewRequest(http.MethodGet, "/old-url", http.NoBody)
+				rr := httptest.NewRecorder()
+				redirectProxy.Router.ServeHTTP(rr, req)
+
+				So(redisClientMock.GetValueCalls()[0].Key, ShouldEqual, "redirect:/old-url")
 			})
 		})
 
@@ -254,8 +317,22 @@ func TestProxyHandleRequestError(t *testing.T) {
 			r := httptest.NewRequest(http.MethodGet, "/test-endpoint", http.NoBody)
 			testProxy.Router.ServeHTTP(w, r)
 
-			Convey("Then the proxy should return a 502 Bad Gateway", func() {
+			Convey("Then the proxy should return a 502 Bad Gateway with an error page", func() {
 				So(w.Code, ShouldEqual, http.StatusBadGateway)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+				So(w.Body.String(), ShouldContainSubstring, "The service is temporarily unavailable.")
+			})
+		})
+
+		Convey("When a request that accepts JSON is sent", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/test-endpoint", http.NoBody)
+			r.Header.Set("Accept", "application/json")
+			testProxy.Router.ServeHTTP(w, r)
+
+			Convey("Then the proxy should return a 502 Bad Gateway with a JSON error", func() {
+				So(w.Code, ShouldEqual, http.StatusBadGateway)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
 			})
 		})
 	})
@@ -296,6 +373,80 @@ func TestProxyHandleCustomHeaderAndBody(t *testing.T) {
 	})
 }
 
+func TestProxyHandleUpstreamHeaderRules(t *testing.T) {
+	Convey("Given a Proxy with a header rule for the legacy upstream", t, func() {
+		received := make(chan *http.Request, 1)
+		mockTargetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- r
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer mockTargetServer.Close()
+
+		ctx := context.Background()
+		router := mux.NewRouter()
+		cfg := &config.Config{
+			ProxiedServiceURL: mockTargetServer.URL,
+			UpstreamHeaderRules: config.HeaderRules{
+				"legacy":  {Remove: []string{"Cookie"}},
+				"wagtail": {Set: map[string]string{"Authorization": "Bearer wagtail-token"}},
+			},
+		}
+		testProxy, err := proxy.Setup(ctx, router, cfg, &clientMocks.RedisMock{})
+		So(err, ShouldBeNil)
+
+		Convey("When a request with cookies is sent", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/test-endpoint", http.NoBody)
+			r.Header.Set("Cookie", "access_token=abc")
+			testProxy.Router.ServeHTTP(w, r)
+			upstreamReq := <-received
+
+			Convey("Then only the legacy rule is applied to the forwarded request", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(upstreamReq.Header.Get("Cookie"), ShouldBeEmpty)
+				So(upstreamReq.Header.Get("Authorization"), ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestProxyHandleResponseHeaderPolicies(t *testing.T) {
+	Convey("Given a Proxy with response header policies and a target that leaks its server software", t, func() {
+		mockTargetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Server", "Apache")
+			w.Header().Set("X-Powered-By", "PHP")
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer mockTargetServer.Close()
+
+		ctx := context.Background()
+		router := mux.NewRouter()
+		cfg := &config.Config{
+			ProxiedServiceURL: mockTargetServer.URL,
+			ResponseHeaderPolicies: config.HeaderPolicies{{
+				PathPrefix: "/",
+				Set:        map[string]string{"X-Content-Type-Options": "nosniff"},
+				Remove:     []string{"Server", "X-Powered-By"},
+			}},
+		}
+		testProxy, err := proxy.Setup(ctx, router, cfg, &clientMocks.RedisMock{})
+		So(err, ShouldBeNil)
+
+		Convey("When a request is sent", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/test-endpoint", http.NoBody)
+			testProxy.Router.ServeHTTP(w, r)
+
+			Convey("Then the policy is applied to the proxied response", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("X-Content-Type-Options"), ShouldEqual, "nosniff")
+				So(w.Header().Get("Server"), ShouldBeEmpty)
+				So(w.Header().Get("X-Powered-By"), ShouldBeEmpty)
+			})
+		})
+	})
+}
+
 func TestProxyHandleFallback(t *testing.T) {
 	Convey("Given a Proxy with releases fallback enabled and a mock Wagtail that returns 404", t, func() {
 		// Mock Wagtail server that always returns 404
@@ -459,3 +610,57 @@ func hasRoute(r *mux.Router, path, method string) bool {
 	match := &mux.RouteMatch{}
 	return r.Match(req, match)
 }
+
+func TestProxyRuntimeFeatureFlags(t *testing.T) {
+	Convey("Given a Proxy whose feature flags are read from a file", t, func() {
+		redisClientMock := &clientMocks.RedisMock{
+			GetValueFunc: func(ctx context.Context, key string) (string, error) {
+				return "/new-url", nil
+			},
+		}
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer upstream.Close()
+
+		flagsFile := filepath.Join(t.TempDir(), "flags.json")
+		So(os.WriteFile(flagsFile, []byte(`{"enable_redirects": false}`), 0o600), ShouldBeNil)
+
+		cfg := &config.Config{
+			EnableRedirects:          true,
+			FeatureFlagsFile:         flagsFile,
+			FeatureFlagsPollInterval: 10 * time.Millisecond,
+			FeatureFlagsSource:       config.FeatureFlagsSourceFile,
+			ProxiedServiceURL:        upstream.URL,
+		}
+		testProxy, err := proxy.Setup(context.Background(), mux.NewRouter(), cfg, redisClientMock)
+		So(err, ShouldBeNil)
+		defer testProxy.Flags.Stop()
+
+		serve := func() int {
+			rr := httptest.NewRecorder()
+			testProxy.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/old-url", http.NoBody))
+			return rr.Code
+		}
+
+		Convey("When the file disables redirects", func() {
+			Convey("Then requests are proxied without checking Redis", func() {
+				So(serve(), ShouldEqual, http.StatusOK)
+				So(redisClientMock.GetValueCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the file is changed to enable redirects", func() {
+			So(os.WriteFile(flagsFile, []byte(`{"enable_redirects": true}`), 0o600), ShouldBeNil)
+			So(os.Chtimes(flagsFile, time.Now(), time.Now().Add(time.Minute)), ShouldBeNil)
+
+			Convey("Then requests are redirected without rebuilding the router", func() {
+				deadline := time.Now().Add(time.Second)
+				for serve() != http.StatusPermanentRedirect && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+				}
+				So(serve(), ShouldEqual, http.StatusPermanentRedirect)
+			})
+		})
+	})
+}
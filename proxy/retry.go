@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/metrics"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// budgetCapacitySeconds is how many seconds' worth of the minimum retry rate the budget can bank
+const budgetCapacitySeconds = 10
+
+// RetryPolicy describes when and how idempotent upstream requests are retried
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	StatusCodes    []int
+	// Errors are the reasons, as returned by retryableErrorReason, of the connection errors to retry
+	Errors []string
+}
+
+// NewRetryPolicy returns the retry policy described by the service configuration
+func NewRetryPolicy(cfg *config.Config) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    cfg.ProxyRetryMaxAttempts,
+		InitialBackoff: cfg.ProxyRetryInitialBackoff,
+		MaxBackoff:     cfg.ProxyRetryMaxBackoff,
+		StatusCodes:    cfg.ProxyRetryStatusCodes,
+		Errors:         cfg.ProxyRetryErrors,
+	}
+}
+
+// backoff returns a fully jittered delay for the given retry (1 being the first retry)
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	if p.InitialBackoff <= 0 {
+		return 0
+	}
+	ceiling := p.InitialBackoff << (retry - 1)
+	if ceiling <= 0 || (p.MaxBackoff > 0 && ceiling > p.MaxBackoff) {
+		ceiling = p.MaxBackoff
+	}
+	return rand.N(ceiling + 1) //nolint:gosec // jitter does not need a cryptographically secure source
+}
+
+// RetryBudget limits the number of retries to a fraction of the original requests, plus a small
+// minimum rate, so that retries cannot amplify the load on an upstream that is already failing.
+type RetryBudget struct {
+	mu           sync.Mutex
+	ratio        float64
+	minPerSecond float64
+	capacity     float64
+	tokens       float64
+	last         time.Time
+	now          func() time.Time
+}
+
+// NewRetryBudget creates a retry budget that allows ratio retries per request and at least
+// minPerSecond retries per second
+func NewRetryBudget(ratio float64, minPerSecond int) *RetryBudget {
+	capacity := float64(minPerSecond * budgetCapacitySeconds)
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RetryBudget{
+		ratio:        ratio,
+		minPerSecond: float64(minPerSecond),
+		capacity:     capacity,
+		tokens:       capacity,
+		last:         time.Now(),
+		now:          time.Now,
+	}
+}
+
+// deposit credits the budget for an original (non-retry) request
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens = min(b.capacity, b.tokens+b.ratio)
+}
+
+// withdraw attempts to spend one retry from the budget, returning false if the budget is exhausted
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *RetryBudget) refill() {
+	now := b.now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.minPerSecond)
+	}
+}
+
+// retryTransport wraps a RoundTripper and retries idempotent requests according to a RetryPolicy
+type retryTransport struct {
+	next     http.RoundTripper
+	policy   RetryPolicy
+	budget   *RetryBudget
+	upstream string
+}
+
+func newRetryTransport(next http.RoundTripper, upstream string, policy RetryPolicy, budget *RetryBudget) *retryTransport {
+	return &retryTransport{
+		next:     next,
+		policy:   policy,
+		budget:   budget,
+		upstream: upstream,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.isRetryable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	t.budget.deposit()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+
+		reason := t.retryReason(resp, err)
+		if reason == "" || attempt >= t.policy.MaxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		if !t.budget.withdraw() {
+			log.Warn(ctx, "retry budget exhausted, not retrying upstream request", log.Data{
+				"upstream": t.upstream, "attempt": attempt, "reason": reason,
+			})
+			metrics.RecordProxyRetryBudgetExhausted(ctx, t.upstream)
+			return resp, err
+		}
+
+		if resp != nil {
+			// discard the failed response so its connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		delay := t.policy.backoff(attempt)
+		log.Info(ctx, "retrying upstream request", log.Data{
+			"upstream": t.upstream, "attempt": attempt + 1, "reason": reason, "backoff": delay.String(),
+		})
+		metrics.RecordProxyRetry(ctx, t.upstream, reason)
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isRetryable reports whether the request is idempotent and can be safely replayed
+func (t *retryTransport) isRetryable(req *http.Request) bool {
+	if t.policy.MaxAttempts <= 1 {
+		return false
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+// retryReason returns a short description of why the attempt should be retried, or an empty string
+// if the outcome is final
+func (t *retryTransport) retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		if reason := retryableErrorReason(err); slices.Contains(t.policy.Errors, reason) {
+			return reason
+		}
+		return ""
+	}
+	if slices.Contains(t.policy.StatusCodes, resp.StatusCode) {
+		return "status_" + strconv.Itoa(resp.StatusCode)
+	}
+	return ""
+}
+
+// retryableErrorReason classifies transient connection errors that are safe to retry, returning an empty string
+// for any other error
+func retryableErrorReason(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ""
+	case errors.Is(err, syscall.ECONNRESET):
+		return config.RetryErrorConnectionReset
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return config.RetryErrorConnectionRefused
+	case errors.Is(err, syscall.EPIPE):
+		return config.RetryErrorBrokenPipe
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return config.RetryErrorConnectionClosed
+	}
+	return ""
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
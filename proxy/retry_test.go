@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetryTransport(t *testing.T) {
+	Convey("Given an upstream that fails with a 503 before succeeding", t, func() {
+		var calls atomic.Int32
+		failures := int32(1)
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if calls.Add(1) <= failures {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer upstream.Close()
+
+		policy := RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     2 * time.Millisecond,
+			StatusCodes:    []int{http.StatusServiceUnavailable},
+		}
+		client := &http.Client{
+			Transport: newRetryTransport(http.DefaultTransport, "test", policy, NewRetryBudget(1, 10)),
+		}
+
+		Convey("When a GET request is sent", func() {
+			resp, err := client.Get(upstream.URL)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+
+			Convey("Then it is retried and the successful response is returned", func() {
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When a POST request is sent", func() {
+			resp, err := client.Post(upstream.URL, "text/plain", strings.NewReader("body"))
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+
+			Convey("Then it is not retried", func() {
+				So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+				So(calls.Load(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the upstream keeps failing", func() {
+			failures = 10
+			resp, err := client.Get(upstream.URL)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+
+			Convey("Then the request is attempted no more than the maximum number of times", func() {
+				So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+				So(calls.Load(), ShouldEqual, 3)
+			})
+		})
+	})
+}
+
+// roundTripFunc is an http.RoundTripper that calls the function
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryTransportErrors(t *testing.T) {
+	Convey("Given an upstream connection that is reset before the request succeeds", t, func() {
+		var calls atomic.Int32
+		next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if calls.Add(1) == 1 {
+				return nil, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		})
+		policy := RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     2 * time.Millisecond,
+		}
+
+		Convey("When resets are configured to be retried", func() {
+			policy.Errors = []string{config.RetryErrorConnectionReset}
+			client := &http.Client{Transport: newRetryTransport(next, "test", policy, NewRetryBudget(1, 10))}
+			resp, err := client.Get("http://upstream.test/economy")
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+
+			Convey("Then the request is retried and the successful response is returned", func() {
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When only other errors are configured to be retried", func() {
+			policy.Errors = []string{config.RetryErrorConnectionRefused}
+			client := &http.Client{Transport: newRetryTransport(next, "test", policy, NewRetryBudget(1, 10))}
+			_, err := client.Get("http://upstream.test/economy")
+
+			Convey("Then the request is not retried and the error is returned", func() {
+				So(errors.Is(err, syscall.ECONNRESET), ShouldBeTrue)
+				So(calls.Load(), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestRetryBudget(t *testing.T) {
+	Convey("Given a retry budget with a 25% ratio and no minimum rate", t, func() {
+		now := time.Now()
+		budget := NewRetryBudget(0.25, 0)
+		budget.now = func() time.Time { return now }
+		budget.last = now
+		budget.tokens = 0
+
+		Convey("When fewer than four requests have been made", func() {
+			for range 3 {
+				budget.deposit()
+			}
+
+			Convey("Then no retry is allowed", func() {
+				So(budget.withdraw(), ShouldBeFalse)
+			})
+		})
+
+		Convey("When four requests have been made", func() {
+			for range 4 {
+				budget.deposit()
+			}
+
+			Convey("Then exactly one retry is allowed", func() {
+				So(budget.withdraw(), ShouldBeTrue)
+				So(budget.withdraw(), ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given an exhausted retry budget with a minimum rate", t, func() {
+		now := time.Now()
+		budget := NewRetryBudget(0, 2)
+		budget.now = func() time.Time { return now }
+		budget.last = now
+		budget.tokens = 0
+
+		Convey("When a second passes", func() {
+			now = now.Add(time.Second)
+
+			Convey("Then the minimum number of retries is replenished", func() {
+				So(budget.withdraw(), ShouldBeTrue)
+				So(budget.withdraw(), ShouldBeTrue)
+				So(budget.withdraw(), ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/metrics"
+	"github.com/ONSdigital/dis-redirect-proxy/middleware"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+)
+
+// KeyPrefix is prepended to every rate limit key, so buckets can share a Redis database with redirects
+const KeyPrefix = "ratelimit:"
+
+// errorLogInterval is the least time between logs of store errors. While Redis is unavailable every request fails
+// to take a token, and the outage is already reported by the Redis health check.
+const errorLogInterval = time.Minute
+
+// Scopes of a limit, used in keys, logs and metrics
+const (
+	scopeClient = "client"
+	scopeRoute  = "route"
+)
+
+// Limiter rate limits requests by client IP address, and optionally by route as well
+type Limiter struct {
+	store       Store
+	limit       Limit
+	rules       config.RateLimitRules
+	trustedHops int
+	errorLog    errorLog
+}
+
+// errorLog limits how often store errors are logged, counting the errors that aren't
+type errorLog struct {
+	mu      sync.Mutex
+	last    time.Time
+	skipped int
+}
+
+// allow reports whether an error at now should be logged, returning the number of errors that weren't logged
+// since the last one that was
+func (e *errorLog) allow(now time.Time) (bool, int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.last.IsZero() && now.Sub(e.last) < errorLogInterval {
+		e.skipped++
+		return false, 0
+	}
+	skipped := e.skipped
+	e.last, e.skipped = now, 0
+	return true, skipped
+}
+
+// New creates a Limiter from the service configuration, keeping buckets in Redis if configured to
+func New(cfg *config.Config, redisCli clients.Redis) (*Limiter, error) {
+	var store Store
+	switch cfg.RateLimitStore {
+	case config.RateLimitStoreMemory, "":
+		store = NewMemoryStore()
+	case config.RateLimitStoreRedis:
+		if redisCli == nil {
+			return nil, errors.New("redis rate limit store requires a redis client")
+		}
+		store = NewRedisStore(redisCli)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store: %q", cfg.RateLimitStore)
+	}
+
+	limit := Limit{Rate: cfg.RateLimitRequestsPerSecond, Burst: cfg.RateLimitBurst}
+	return NewWithStore(store, limit, cfg.RateLimitRules, cfg.TrustedProxyHops), nil
+}
+
+// NewWithStore creates a Limiter using the given store. limit applies to all requests from a client and
+// rules add limits for particular routes; a limit with no rate is not enforced. trustedHops is the number
+// of proxies in front of this one that append to X-Forwarded-For.
+func NewWithStore(store Store, limit Limit, rules config.RateLimitRules, trustedHops int) *Limiter {
+	return &Limiter{
+		store:       store,
+		limit:       limit,
+		rules:       rules,
+		trustedHops: trustedHops,
+	}
+}
+
+// Middleware returns a middleware that responds with 429 Too Many Requests, and a Retry-After header, to
+// clients that are over a limit. If the store can't be reached the request is allowed, so that a Redis
+// outage doesn't take the site down with it.
+func (l *Limiter) Middleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			// Skip rate limiting for the health endpoint and the liveness and readiness probes
+			switch req.URL.Path {
+			case "/health", "/livez", "/readyz":
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			ctx := req.Context()
+			ip := middleware.ClientIP(req, l.trustedHops)
+
+			// the route limit is checked first, so a request refused by it doesn't use up the client's limit
+			if rule := matchRule(l.rules, req.URL.Path); rule != nil {
+				key := KeyPrefix + scopeRoute + ":" + rule.PathPrefix + ":" + ip
+				limit := Limit{Rate: rule.RequestsPerSecond, Burst: rule.Burst}
+				if allowed := l.take(ctx, scopeRoute, key, limit, w, ip); !allowed {
+					return
+				}
+			}
+			if allowed := l.take(ctx, scopeClient, KeyPrefix+scopeClient+":"+ip, l.limit, w, ip); !allowed {
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// take takes a token for key, writing a 429 response and returning false if there wasn't one
+func (l *Limiter) take(ctx context.Context, scope, key string, limit Limit, w http.ResponseWriter, ip string) bool {
+	if limit.Rate <= 0 {
+		return true
+	}
+
+	allowed, retryAfter, err := l.store.Take(ctx, key, limit)
+	if err != nil {
+		if ok, skipped := l.errorLog.allow(time.Now()); ok {
+			log.Error(ctx, "error checking rate limit, allowing requests", err, log.Data{"key": key, "errors_not_logged": skipped})
+		}
+		return true
+	}
+	if allowed {
+		return true
+	}
+
+	log.Warn(ctx, "rate limit exceeded", log.Data{"client_ip": ip, "scope": scope, "key": key})
+	metrics.RecordRateLimited(ctx, scope)
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	return false
+}
+
+// matchRule returns the rule with the longest path prefix matching path, or nil if none match
+func matchRule(rules config.RateLimitRules, path string) *config.RateLimitRule {
+	var match *config.RateLimitRule
+	for i := range rules {
+		rule := &rules[i]
+		if strings.HasPrefix(path, rule.PathPrefix) && (match == nil || len(rule.PathPrefix) > len(match.PathPrefix)) {
+			match = rule
+		}
+	}
+	return match
+}
@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients/mock"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/redis/go-redis/v9"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLimiterMiddleware(t *testing.T) {
+	Convey("Given a rate limited handler", t, func() {
+		now := time.Now()
+		store := NewMemoryStore()
+		store.now = func() time.Time { return now }
+		limiter := NewWithStore(store, Limit{Rate: 1, Burst: 2}, config.RateLimitRules{
+			{PathPrefix: "/search", RequestsPerSecond: 0.5, Burst: 1},
+		}, 1)
+
+		var calls int
+		handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		serve := func(path, forwardedFor string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When a client makes more requests than its burst allows", func() {
+			serve("/economy", "10.0.0.1")
+			serve("/economy", "10.0.0.1")
+			w := serve("/economy", "10.0.0.1")
+
+			Convey("Then the extra request is rejected with a Retry-After", func() {
+				So(calls, ShouldEqual, 2)
+				So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+				So(w.Header().Get("Retry-After"), ShouldEqual, "1")
+			})
+
+			Convey("And other clients are not affected", func() {
+				So(serve("/economy", "10.0.0.2").Code, ShouldEqual, http.StatusOK)
+			})
+
+			Convey("And the client can make requests again once its bucket refills", func() {
+				now = now.Add(time.Second)
+				So(serve("/economy", "10.0.0.1").Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When a client spoofs the start of X-Forwarded-For", func() {
+			serve("/economy", "1.1.1.1, 10.0.0.1")
+			serve("/economy", "2.2.2.2, 10.0.0.1")
+			w := serve("/economy", "3.3.3.3, 10.0.0.1")
+
+			Convey("Then the address added by the trusted proxy is limited", func() {
+				So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			})
+		})
+
+		Convey("When a client makes more requests to a route than the route's limit allows", func() {
+			serve("/search?q=gdp", "10.0.0.1")
+			w := serve("/search?q=cpi", "10.0.0.1")
+
+			Convey("Then the request is rejected", func() {
+				So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+				So(w.Header().Get("Retry-After"), ShouldEqual, "2")
+			})
+
+			Convey("And requests to other routes are allowed", func() {
+				So(serve("/economy", "10.0.0.1").Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When the health endpoint and probes are called repeatedly", func() {
+			for range 5 {
+				serve("/health", "10.0.0.1")
+				serve("/livez", "10.0.0.1")
+				serve("/readyz", "10.0.0.1")
+			}
+
+			Convey("Then they are never limited", func() {
+				So(calls, ShouldEqual, 15)
+			})
+		})
+	})
+
+	Convey("Given a rate limited handler whose store fails", t, func() {
+		redisMock := &mock.RedisMock{
+			RunScriptFunc: func(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+				return nil, errors.New("redis unavailable")
+			},
+		}
+		limiter := NewWithStore(NewRedisStore(redisMock), Limit{Rate: 1, Burst: 1}, nil, 0)
+		handler := limiter.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		Convey("When requests are made", func() {
+			codes := make([]int, 3)
+			for i := range codes {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/economy", http.NoBody))
+				codes[i] = w.Code
+			}
+
+			Convey("Then they are allowed", func() {
+				So(codes, ShouldResemble, []int{http.StatusOK, http.StatusOK, http.StatusOK})
+			})
+
+			Convey("And only the first error is logged", func() {
+				So(limiter.errorLog.skipped, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestErrorLog(t *testing.T) {
+	Convey("Given an error log that has logged an error", t, func() {
+		var errorLog errorLog
+		start := time.Now()
+		logged, _ := errorLog.allow(start)
+		So(logged, ShouldBeTrue)
+
+		Convey("When more errors happen within the interval", func() {
+			first, _ := errorLog.allow(start.Add(time.Second))
+			second, _ := errorLog.allow(start.Add(errorLogInterval - time.Second))
+
+			Convey("Then they aren't logged", func() {
+				So(first, ShouldBeFalse)
+				So(second, ShouldBeFalse)
+			})
+
+			Convey("And the next error after the interval is logged with the number that weren't", func() {
+				logged, skipped := errorLog.allow(start.Add(errorLogInterval))
+				So(logged, ShouldBeTrue)
+				So(skipped, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func TestRedisStore(t *testing.T) {
+	Convey("Given a Redis store", t, func() {
+		ctx := context.Background()
+		var result interface{} = []interface{}{int64(1), int64(0)}
+		redisMock := &mock.RedisMock{
+			RunScriptFunc: func(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+				return result, nil
+			},
+		}
+		now := time.Now()
+		store := NewRedisStore(redisMock)
+		store.now = func() time.Time { return now }
+		limit := Limit{Rate: 2, Burst: 1}
+
+		Convey("When a token is taken", func() {
+			allowed, _, err := store.Take(ctx, "ratelimit:client:10.0.0.1", limit)
+
+			Convey("Then it is taken atomically by the script, with the limit and the time", func() {
+				So(err, ShouldBeNil)
+				So(allowed, ShouldBeTrue)
+				call := redisMock.RunScriptCalls()[0]
+				So(call.Script, ShouldEqual, takeScript)
+				So(call.Keys, ShouldResemble, []string{"ratelimit:client:10.0.0.1"})
+				So(call.Args, ShouldResemble, []interface{}{2.0, 1, now.UnixMicro()})
+			})
+		})
+
+		Convey("When the bucket is empty", func() {
+			result = []interface{}{int64(0), int64(500000)}
+			allowed, retryAfter, err := store.Take(ctx, "ratelimit:client:10.0.0.1", limit)
+
+			Convey("Then the request is refused until the bucket refills", func() {
+				So(err, ShouldBeNil)
+				So(allowed, ShouldBeFalse)
+				So(retryAfter, ShouldEqual, 500*time.Millisecond)
+			})
+		})
+
+		Convey("When the script returns something unexpected", func() {
+			result = "OK"
+			_, _, err := store.Take(ctx, "ratelimit:client:10.0.0.1", limit)
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldBeError, "unexpected result from rate limit script: OK")
+			})
+		})
+	})
+}
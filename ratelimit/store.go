@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/redis/go-redis/v9"
+)
+
+// sweepInterval is how often a MemoryStore removes buckets that have refilled and so are no longer needed
+const sweepInterval = time.Minute
+
+// Limit is a token bucket: Burst requests can be made at once, and the bucket refills at Rate requests
+// per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Store holds the token buckets for rate limited keys
+type Store interface {
+	// Take removes a token from the bucket for key, returning false and the time until a token will be
+	// available if the bucket is empty
+	Take(ctx context.Context, key string, limit Limit) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// bucket is the state of a token bucket
+type bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// take refills the bucket for the time since it was last updated and then tries to remove a token from it
+func (b *bucket) take(limit Limit, now time.Time) (bool, time.Duration) {
+	elapsed := now.Sub(b.Updated).Seconds()
+	b.Tokens = math.Min(float64(limit.Burst), b.Tokens+math.Max(elapsed, 0)*limit.Rate)
+	b.Updated = now
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.Tokens) / limit.Rate * float64(time.Second))
+}
+
+// refilledAt is when the bucket will be full again, after which it is the same as a new bucket
+func (b *bucket) refilledAt(limit Limit) time.Time {
+	return b.Updated.Add(time.Duration((float64(limit.Burst) - b.Tokens) / limit.Rate * float64(time.Second)))
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{Tokens: float64(limit.Burst), Updated: now}
+}
+
+// MemoryStore is an in-process Store, for when the proxy runs as a single instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: *newBucket(limit, now)}
+		s.buckets[key] = b
+	}
+	allowed, retryAfter := b.take(limit, now)
+	b.expires = b.refilledAt(limit)
+	return allowed, retryAfter, nil
+}
+
+// sweep removes full buckets, which would be recreated in the same state if needed again
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.expires) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// takeScript takes a token from the bucket in the hash at KEYS[1], refilling it at ARGV[1] tokens per second up
+// to ARGV[2] tokens for the time since it was updated, at ARGV[3] microseconds since the epoch. It returns 1 if a
+// token was taken, or 0 and the microseconds until one will be available. Redis runs a script atomically, so
+// concurrent requests for the same bucket can't spend the same token.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1]) or burst
+local updated = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(now - updated, 0) / 1e6 * rate)
+
+local allowed, wait = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) / rate * 1e6)
+end
+
+redis.call("HSET", KEYS[1], "tokens", string.format("%.17g", tokens), "updated", ARGV[3])
+-- a bucket that has refilled is the same as a missing one, so it only needs keeping until then
+redis.call("PEXPIRE", KEYS[1], math.max(math.ceil((burst - tokens) / rate * 1e3), 1000))
+return {allowed, wait}
+`)
+
+// RedisStore is a Store backed by Redis, so that limits are shared between proxy instances. Each bucket is a
+// hash, which is updated atomically by a script.
+type RedisStore struct {
+	client clients.Redis
+	now    func() time.Time
+}
+
+// NewRedisStore creates a RedisStore using the given client
+func NewRedisStore(client clients.Redis) *RedisStore {
+	return &RedisStore{client: client, now: time.Now}
+}
+
+// Take implements Store
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	result, err := s.client.RunScript(ctx, takeScript, []string{key}, limit.Rate, limit.Burst, s.now().UnixMicro())
+	if err != nil {
+		return false, 0, err
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("unexpected result from rate limit script: %v", result)
+	}
+	allowed, _ := values[0].(int64)
+	wait, _ := values[1].(int64)
+	return allowed == 1, time.Duration(wait) * time.Microsecond, nil
+}
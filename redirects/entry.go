@@ -0,0 +1,133 @@
+package redirects
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	disRedis "github.com/ONSdigital/dis-redis"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Statuses of a redirect entry, depending on whether the current time is within its window
+const (
+	StatusActive   = "active"
+	StatusUpcoming = "upcoming"
+	StatusExpired  = "expired"
+)
+
+// ErrNoTarget is returned when a redirect entry has no URL to redirect to
+var ErrNoTarget = errors.New("redirect has no target URL")
+
+// ErrInvalidRedirect is returned when a redirect entry can't be parsed, or its window ends before it starts
+var ErrInvalidRedirect = errors.New("invalid redirect")
+
+// ErrInvalidPath is returned when the path of a redirect doesn't start with /, or has braces, which Redis Cluster
+// would read as a hash tag
+var ErrInvalidPath = errors.New("path must start with / and must not contain { or }")
+
+// Entry is a redirect stored in Redis. It is either the bare URL to redirect to, or a JSON object that only
+// redirects within the window from NotBefore up to NotAfter, either of which may be omitted. Redirects set
+// through a Store also hold the metadata of their last change and when they were created and updated, so that
+// they can be looked up with a single read.
+type Entry struct {
+	To        string     `json:"to"`
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+	Metadata
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Metadata describes who changed a redirect, why and with what
+type Metadata struct {
+	Author string `json:"author,omitempty"`
+	Ticket string `json:"ticket,omitempty"`
+	Source string `json:"source,omitempty"`
+}
+
+// Redirect is a redirect entry together with the path it redirects from
+type Redirect struct {
+	Path string `json:"path"`
+	Entry
+}
+
+// ParseEntry parses the value of a redirect in Redis
+func ParseEntry(value string) (Entry, error) {
+	if !strings.HasPrefix(value, "{") {
+		return Entry{To: value}, nil
+	}
+
+	var entry Entry
+	if err := json.Unmarshal([]byte(value), &entry); err != nil {
+		return Entry{}, fmt.Errorf("%w: %w", ErrInvalidRedirect, err)
+	}
+	if err := entry.validate(); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// validate checks that the entry has a target and that its window ends after it starts
+func (e Entry) validate() error {
+	if e.To == "" {
+		return ErrNoTarget
+	}
+	if e.NotBefore != nil && e.NotAfter != nil && !e.NotAfter.After(*e.NotBefore) {
+		return fmt.Errorf("%w: not_after %s must be after not_before %s", ErrInvalidRedirect,
+			e.NotAfter.Format(time.RFC3339), e.NotBefore.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Status returns whether the entry is active at now, or is upcoming or has expired
+func (e Entry) Status(now time.Time) string {
+	switch {
+	case e.NotBefore != nil && now.Before(*e.NotBefore):
+		return StatusUpcoming
+	case e.NotAfter != nil && !now.Before(*e.NotAfter):
+		return StatusExpired
+	default:
+		return StatusActive
+	}
+}
+
+// List returns the redirects under keyPrefix whose status at now is status, sorted by path. The keys are scanned
+// on every primary of a cluster, and read one at a time as they can be in different slots. Entries that can't be
+// parsed are logged and left out.
+func List(ctx context.Context, client clients.Redis, keyPrefix, status string, now time.Time) ([]Redirect, error) {
+	var list []Redirect
+	err := client.ScanKeys(ctx, clients.EscapeGlob(keyPrefix)+"/*", scanCount, func(keys []string) error {
+		for _, key := range keys {
+			value, err := client.GetValue(ctx, key)
+			if errors.Is(err, disRedis.ErrKeyNotFound) {
+				// the redirect was deleted after it was scanned
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			path := strings.TrimPrefix(key, keyPrefix)
+			entry, err := ParseEntry(value)
+			if err != nil {
+				log.Warn(ctx, "skipping invalid redirect", log.Data{"path": path, "error": err.Error()})
+				continue
+			}
+			if entry.Status(now) == status {
+				list = append(list, Redirect{Path: path, Entry: entry})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(list, func(a, b Redirect) int { return strings.Compare(a.Path, b.Path) })
+	return list, nil
+}
@@ -0,0 +1,144 @@
+package redirects_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients/mock"
+	"github.com/ONSdigital/dis-redirect-proxy/redirects"
+	disRedis "github.com/ONSdigital/dis-redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseEntry(t *testing.T) {
+	Convey("Given a redirect stored as a bare URL", t, func() {
+		entry, err := redirects.ParseEntry("/economy/new")
+
+		Convey("Then it redirects to the URL at any time", func() {
+			So(err, ShouldBeNil)
+			So(entry, ShouldResemble, redirects.Entry{To: "/economy/new"})
+			So(entry.Status(time.Now()), ShouldEqual, redirects.StatusActive)
+		})
+	})
+
+	Convey("Given a redirect stored with a window", t, func() {
+		entry, err := redirects.ParseEntry(`{"to":"/releases/new","not_before":"2026-03-01T09:30:00Z","not_after":"2026-04-01T00:00:00Z"}`)
+		So(err, ShouldBeNil)
+
+		Convey("Then it is upcoming before the window", func() {
+			So(entry.Status(time.Date(2026, 3, 1, 9, 29, 0, 0, time.UTC)), ShouldEqual, redirects.StatusUpcoming)
+		})
+
+		Convey("Then it is active from the start of the window", func() {
+			So(entry.To, ShouldEqual, "/releases/new")
+			So(entry.Status(time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)), ShouldEqual, redirects.StatusActive)
+		})
+
+		Convey("Then it has expired from the end of the window", func() {
+			So(entry.Status(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)), ShouldEqual, redirects.StatusExpired)
+		})
+	})
+
+	Convey("Given a redirect stored with only an end", t, func() {
+		entry, err := redirects.ParseEntry(`{"to":"/campaign","not_after":"2026-04-01T00:00:00Z"}`)
+		So(err, ShouldBeNil)
+
+		Convey("Then it is active until the end", func() {
+			So(entry.Status(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)), ShouldEqual, redirects.StatusActive)
+		})
+	})
+
+	Convey("Given a redirect without a target", t, func() {
+		_, err := redirects.ParseEntry(`{"not_after":"2026-04-01T00:00:00Z"}`)
+
+		Convey("Then it is invalid", func() {
+			So(err, ShouldEqual, redirects.ErrNoTarget)
+		})
+	})
+
+	Convey("Given a redirect whose window ends before it starts", t, func() {
+		_, err := redirects.ParseEntry(`{"to":"/campaign","not_before":"2026-04-01T00:00:00Z","not_after":"2026-03-01T00:00:00Z"}`)
+
+		Convey("Then it is invalid", func() {
+			So(err, ShouldBeError, "invalid redirect: not_after 2026-03-01T00:00:00Z must be after not_before 2026-04-01T00:00:00Z")
+		})
+	})
+
+	Convey("Given a redirect that isn't valid JSON", t, func() {
+		_, err := redirects.ParseEntry(`{"to":`)
+
+		Convey("Then it is invalid", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestList(t *testing.T) {
+	Convey("Given Redis holds redirects with different windows", t, func() {
+		ctx := context.Background()
+		now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+		values := map[string]string{
+			"redirect:/old":       "/new",
+			"redirect:/launch":    `{"to":"/launched","not_before":"2026-04-01T00:00:00Z"}`,
+			"redirect:/a-launch":  `{"to":"/a-launched","not_before":"2026-05-01T00:00:00Z"}`,
+			"redirect:/campaign":  `{"to":"/campaigns/spring","not_after":"2026-03-01T00:00:00Z"}`,
+			"redirect:/malformed": `{"to":`,
+		}
+		redisMock := &mock.RedisMock{
+			// ScanKeysFunc returns the keys over two pages, as from two primaries of a cluster, along with a key
+			// that is deleted before it is read
+			ScanKeysFunc: func(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error {
+				if err := fn([]string{"redirect:/old", "redirect:/launch", "redirect:/deleted"}); err != nil {
+					return err
+				}
+				return fn([]string{"redirect:/a-launch", "redirect:/campaign", "redirect:/malformed"})
+			},
+			GetValueFunc: func(ctx context.Context, key string) (string, error) {
+				value, ok := values[key]
+				if !ok {
+					return "", disRedis.ErrKeyNotFound
+				}
+				return value, nil
+			},
+		}
+
+		Convey("When the upcoming redirects are listed", func() {
+			list, err := redirects.List(ctx, redisMock, "redirect:", redirects.StatusUpcoming, now)
+			So(err, ShouldBeNil)
+
+			Convey("Then they are returned by path", func() {
+				So(list, ShouldHaveLength, 2)
+				So(list[0].Path, ShouldEqual, "/a-launch")
+				So(list[1].Path, ShouldEqual, "/launch")
+				So(list[1].To, ShouldEqual, "/launched")
+			})
+
+			Convey("And only the redirects under the key prefix are scanned", func() {
+				So(redisMock.ScanKeysCalls()[0].MatchPattern, ShouldEqual, "redirect:/*")
+			})
+		})
+
+		Convey("When the expired redirects are listed", func() {
+			list, err := redirects.List(ctx, redisMock, "redirect:", redirects.StatusExpired, now)
+			So(err, ShouldBeNil)
+
+			Convey("Then only the expired redirect is returned", func() {
+				So(list, ShouldHaveLength, 1)
+				So(list[0].Path, ShouldEqual, "/campaign")
+			})
+		})
+
+		Convey("When Redis can't be read", func() {
+			redisMock.GetValueFunc = func(ctx context.Context, key string) (string, error) {
+				return "", errors.New("connection refused")
+			}
+			_, err := redirects.List(ctx, redisMock, "redirect:", redirects.StatusUpcoming, now)
+
+			Convey("Then the error is returned", func() {
+				So(err, ShouldBeError, "connection refused")
+			})
+		})
+	})
+}
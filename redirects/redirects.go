@@ -0,0 +1,90 @@
+package redirects
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// scanCount is the number of keys requested per SCAN iteration when listing redirects in Redis
+const scanCount = 1000
+
+// ErrNoKeyPrefix is returned when migrating keys without a key prefix to migrate them to
+var ErrNoKeyPrefix = errors.New("REDIRECT_KEY_PREFIX must be set to migrate redirect keys")
+
+// Key returns the Redis key of the redirect from path, which is the path under keyPrefix
+func Key(keyPrefix, path string) string {
+	return keyPrefix + path
+}
+
+// Migration is the outcome of migrating redirect keys under a key prefix
+type Migration struct {
+	// Migrated is the number of keys renamed under the prefix
+	Migrated int
+	// Skipped are the keys that were kept, because their key under the prefix already exists
+	Skipped []string
+}
+
+// MigrateKeys renames the redirects stored under their bare path, as they were before REDIRECT_KEY_PREFIX, and
+// their histories to keys under keyPrefix. A key that already exists under keyPrefix, such as a redirect set after
+// the prefix was rolled out, is never replaced, and the unprefixed key is skipped instead. Each key is renamed
+// atomically, keeping its expiry, but redirects shouldn't be changed while migrating as the keys are found by
+// scanning. Other keys, such as the maintenance windows, feature flags, cache and rate limits, are never prefixed.
+func MigrateKeys(ctx context.Context, client clients.Redis, keyPrefix string) (Migration, error) {
+	var migration Migration
+	if keyPrefix == "" {
+		return migration, ErrNoKeyPrefix
+	}
+
+	// the key prefix can't start with /, so only unprefixed redirects are matched
+	err := migration.renameKeys(ctx, client, "/*", func(key string) string {
+		return Key(keyPrefix, key)
+	})
+	if err != nil {
+		return migration, err
+	}
+	// the history of an unprefixed redirect has its path as its hash tag
+	err = migration.renameKeys(ctx, client, historyKeyPrefix+"{/*}", func(key string) string {
+		path := strings.TrimSuffix(strings.TrimPrefix(key, historyKeyPrefix+"{"), "}")
+		return HistoryKey(keyPrefix, path)
+	})
+	if err != nil {
+		return migration, err
+	}
+
+	if len(migration.Skipped) > 0 {
+		log.Warn(ctx, "redirect keys skipped as they already exist under the key prefix",
+			log.Data{"key_prefix": keyPrefix, "skipped": migration.Skipped})
+	}
+	log.Info(ctx, "migrated redirect keys", log.Data{"key_prefix": keyPrefix, "migrated": migration.Migrated})
+	return migration, nil
+}
+
+// renameKeys renames the keys matching pattern to the keys returned by newKey, unless they already exist, and
+// records the outcome in m
+func (m *Migration) renameKeys(ctx context.Context, client clients.Redis, pattern string, newKey func(key string) string) error {
+	// a scan can return a key more than once
+	seen := map[string]bool{}
+	return client.ScanKeys(ctx, pattern, scanCount, func(keys []string) error {
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			renamed, err := client.RenameNX(ctx, key, newKey(key))
+			if err != nil {
+				return err
+			}
+			if renamed {
+				m.Migrated++
+			} else {
+				m.Skipped = append(m.Skipped, key)
+			}
+		}
+		return nil
+	})
+}
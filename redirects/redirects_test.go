@@ -0,0 +1,112 @@
+package redirects_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients/mock"
+	"github.com/ONSdigital/dis-redirect-proxy/redirects"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKey(t *testing.T) {
+	Convey("Given a key prefix", t, func() {
+		Convey("Then the key of a redirect is its path under the prefix", func() {
+			So(redirects.Key("redirect:", "/economy/old"), ShouldEqual, "redirect:/economy/old")
+		})
+
+		Convey("Then the key of a redirect is its path when there is no prefix", func() {
+			So(redirects.Key("", "/economy/old"), ShouldEqual, "/economy/old")
+		})
+	})
+}
+
+func TestMigrateKeys(t *testing.T) {
+	Convey("Given Redis holds unprefixed redirects over two pages of a scan, and the history of one", t, func() {
+		ctx := context.Background()
+		store := map[string]string{
+			"/economy/old":           "/economy/new",
+			"/people/old":            "/people/new",
+			"history:{/economy/old}": `{"action":"set"}`,
+		}
+		redisMock := &mock.RedisMock{
+			// ScanKeysFunc returns the unprefixed keys in the store matching the pattern, the same key twice
+			// as a scan may
+			ScanKeysFunc: func(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error {
+				if matchPattern == "history:{/*}" {
+					return fn([]string{"history:{/economy/old}"})
+				}
+				if err := fn([]string{"/economy/old", "/people/old"}); err != nil {
+					return err
+				}
+				return fn([]string{"/people/old"})
+			},
+			RenameNXFunc: func(ctx context.Context, key, newKey string) (bool, error) {
+				if _, exists := store[newKey]; exists {
+					return false, nil
+				}
+				store[newKey] = store[key]
+				delete(store, key)
+				return true, nil
+			},
+		}
+
+		Convey("When they are migrated to a key prefix", func() {
+			migration, err := redirects.MigrateKeys(ctx, redisMock, "redirect:")
+			So(err, ShouldBeNil)
+
+			Convey("Then every redirect and history is renamed under the prefix", func() {
+				So(migration, ShouldResemble, redirects.Migration{Migrated: 3})
+				So(store, ShouldResemble, map[string]string{
+					"redirect:/economy/old":                    "/economy/new",
+					"redirect:/people/old":                     "/people/new",
+					"redirect:history:{redirect:/economy/old}": `{"action":"set"}`,
+				})
+			})
+
+			Convey("And only keys starting with / or the history prefix are scanned", func() {
+				So(redisMock.ScanKeysCalls()[0].MatchPattern, ShouldEqual, "/*")
+				So(redisMock.ScanKeysCalls()[1].MatchPattern, ShouldEqual, "history:{/*}")
+			})
+
+			Convey("And a key returned twice by the scan is only renamed once", func() {
+				So(redisMock.RenameNXCalls(), ShouldHaveLength, 3)
+			})
+		})
+
+		Convey("When a redirect has already been set under the key prefix", func() {
+			store["redirect:/people/old"] = "/people/newer"
+			migration, err := redirects.MigrateKeys(ctx, redisMock, "redirect:")
+			So(err, ShouldBeNil)
+
+			Convey("Then it isn't replaced, and the unprefixed redirect is skipped and reported", func() {
+				So(migration, ShouldResemble, redirects.Migration{Migrated: 2, Skipped: []string{"/people/old"}})
+				So(store["redirect:/people/old"], ShouldEqual, "/people/newer")
+				So(store["/people/old"], ShouldEqual, "/people/new")
+			})
+		})
+
+		Convey("When there is no key prefix", func() {
+			_, err := redirects.MigrateKeys(ctx, redisMock, "")
+
+			Convey("Then nothing is migrated", func() {
+				So(err, ShouldEqual, redirects.ErrNoKeyPrefix)
+				So(redisMock.ScanKeysCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a redirect can't be renamed", func() {
+			redisMock.RenameNXFunc = func(ctx context.Context, key, newKey string) (bool, error) {
+				return false, errors.New("READONLY")
+			}
+			migration, err := redirects.MigrateKeys(ctx, redisMock, "redirect:")
+
+			Convey("Then the error is returned and the unprefixed redirect is kept", func() {
+				So(err, ShouldBeError, "READONLY")
+				So(migration.Migrated, ShouldEqual, 0)
+				So(store, ShouldContainKey, "/economy/old")
+			})
+		})
+	})
+}
@@ -0,0 +1,156 @@
+package redirects
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	disRedis "github.com/ONSdigital/dis-redis"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Actions recorded in the history of a redirect
+const (
+	ActionSet    = "set"
+	ActionDelete = "delete"
+)
+
+// historyKeyPrefix is added to the key prefix of the history of a redirect. It doesn't start with /, so that
+// histories are never mistaken for redirects.
+const historyKeyPrefix = "history:"
+
+// HistoryLimit is the number of changes kept in the history of a redirect, after which the oldest are dropped
+const HistoryLimit = 100
+
+// Change is a change to a redirect, recorded in its history. Entry is the redirect after it was set, and is
+// omitted when it was deleted.
+type Change struct {
+	Action string    `json:"action"`
+	At     time.Time `json:"at"`
+	Metadata
+	Entry *Entry `json:"entry,omitempty"`
+}
+
+// Store sets and deletes redirects in Redis, recording every change in the history of the redirect. The
+// history of a redirect is a list under its own key, so looking up a redirect is still a single read. The key of
+// the history holds the key of the redirect as a hash tag, so that on a cluster both are in the same slot and a
+// change is written in the same transaction as the redirect. Changes are appended to the list, so that concurrent
+// changes are all recorded.
+type Store struct {
+	client    clients.Redis
+	keyPrefix string
+}
+
+// NewStore creates a Store for the redirects under keyPrefix
+func NewStore(client clients.Redis, keyPrefix string) *Store {
+	return &Store{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// HistoryKey returns the Redis key of the history of the redirect from path. The key of the redirect is the hash
+// tag of the history key, which Redis Cluster hashes the same as the key of the redirect as long as it has no
+// braces, which validPath and the config ensure.
+func HistoryKey(keyPrefix, path string) string {
+	return keyPrefix + historyKeyPrefix + "{" + Key(keyPrefix, path) + "}"
+}
+
+// validPath returns whether path can be the path of a redirect set through a Store
+func validPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.ContainsAny(path, "{}")
+}
+
+// Set stores entry as the redirect from path at now, keeping the time it was created if it already exists, and
+// appends the change to its history. It returns the entry as it was stored.
+func (s *Store) Set(ctx context.Context, path string, entry Entry, now time.Time) (Entry, error) {
+	if !validPath(path) {
+		return Entry{}, ErrInvalidPath
+	}
+	if err := entry.validate(); err != nil {
+		return Entry{}, err
+	}
+
+	entry.CreatedAt = &now
+	entry.UpdatedAt = &now
+	value, err := s.client.GetValue(ctx, Key(s.keyPrefix, path))
+	if err != nil && !errors.Is(err, disRedis.ErrKeyNotFound) {
+		return Entry{}, err
+	}
+	if err == nil {
+		if current, err := ParseEntry(value); err == nil && current.CreatedAt != nil {
+			entry.CreatedAt = current.CreatedAt
+		}
+	}
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	change := Change{Action: ActionSet, At: now, Metadata: entry.Metadata, Entry: &entry}
+	if err := s.record(ctx, clients.Command{"SET", Key(s.keyPrefix, path), string(encoded)}, path, change); err != nil {
+		return Entry{}, err
+	}
+	log.Info(ctx, "redirect set", log.Data{"path": path, "to": entry.To, "author": entry.Author, "ticket": entry.Ticket})
+
+	return entry, nil
+}
+
+// Delete deletes the redirect from path at now and appends the change to its history, returning false if there
+// was no redirect to delete
+func (s *Store) Delete(ctx context.Context, path string, metadata Metadata, now time.Time) (bool, error) {
+	if !validPath(path) {
+		return false, ErrInvalidPath
+	}
+
+	_, err := s.client.GetValue(ctx, Key(s.keyPrefix, path))
+	if errors.Is(err, disRedis.ErrKeyNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	change := Change{Action: ActionDelete, At: now, Metadata: metadata}
+	if err := s.record(ctx, clients.Command{"DEL", Key(s.keyPrefix, path)}, path, change); err != nil {
+		return false, err
+	}
+	log.Info(ctx, "redirect deleted", log.Data{"path": path, "author": metadata.Author, "ticket": metadata.Ticket})
+
+	return true, nil
+}
+
+// History returns the last HistoryLimit changes to the redirect from path, oldest first, which is empty if it
+// has never been changed through a Store
+func (s *Store) History(ctx context.Context, path string) ([]Change, error) {
+	values, err := s.client.GetList(ctx, HistoryKey(s.keyPrefix, path))
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]Change, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal([]byte(value), &history[i]); err != nil {
+			return nil, fmt.Errorf("invalid redirect history: %w", err)
+		}
+	}
+	return history, nil
+}
+
+// record runs command, which changes the redirect from path, in a transaction that also appends change to the
+// history of the redirect and drops the changes beyond HistoryLimit
+func (s *Store) record(ctx context.Context, command clients.Command, path string, change Change) error {
+	encoded, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	historyKey := HistoryKey(s.keyPrefix, path)
+	return s.client.Transaction(ctx, command,
+		clients.Command{"RPUSH", historyKey, string(encoded)},
+		clients.Command{"LTRIM", historyKey, -HistoryLimit, -1},
+	)
+}
@@ -0,0 +1,207 @@
+package redirects_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/clients"
+	"github.com/ONSdigital/dis-redirect-proxy/clients/mock"
+	"github.com/ONSdigital/dis-redirect-proxy/redirects"
+	disRedis "github.com/ONSdigital/dis-redis"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHistoryKey(t *testing.T) {
+	Convey("Given a key prefix", t, func() {
+		Convey("Then the key of the history of a redirect has the key of the redirect as its hash tag", func() {
+			So(redirects.HistoryKey("redirect:", "/economy/old"), ShouldEqual, "redirect:history:{redirect:/economy/old}")
+		})
+
+		Convey("Then the key of the history of a redirect has its path as its hash tag when there is no prefix", func() {
+			So(redirects.HistoryKey("", "/economy/old"), ShouldEqual, "history:{/economy/old}")
+		})
+	})
+}
+
+func TestStore(t *testing.T) {
+	Convey("Given a store of redirects in Redis", t, func() {
+		ctx := context.Background()
+		values := map[string]string{}
+		lists := map[string][]string{}
+		redisMock := &mock.RedisMock{
+			GetValueFunc: func(ctx context.Context, key string) (string, error) {
+				value, ok := values[key]
+				if !ok {
+					return "", disRedis.ErrKeyNotFound
+				}
+				return value, nil
+			},
+			GetListFunc: func(ctx context.Context, key string) ([]string, error) {
+				return lists[key], nil
+			},
+			// TransactionFunc applies the commands that the store uses
+			TransactionFunc: func(ctx context.Context, commands ...clients.Command) error {
+				for _, command := range commands {
+					key := command[1].(string)
+					switch command[0] {
+					case "SET":
+						values[key] = command[2].(string)
+					case "DEL":
+						delete(values, key)
+					case "RPUSH":
+						lists[key] = append(lists[key], command[2].(string))
+					case "LTRIM":
+						lists[key] = lists[key][max(len(lists[key])+command[2].(int), 0):]
+					}
+				}
+				return nil
+			},
+		}
+		store := redirects.NewStore(redisMock, "redirect:")
+		created := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+		updated := created.Add(time.Hour)
+
+		Convey("When a redirect is set", func() {
+			entry, err := store.Set(ctx, "/old", redirects.Entry{
+				To:       "/new",
+				Metadata: redirects.Metadata{Author: "alice", Ticket: "DIS-123", Source: "admin"},
+			}, created)
+			So(err, ShouldBeNil)
+
+			Convey("Then it is stored with its metadata under a single key", func() {
+				So(*entry.CreatedAt, ShouldEqual, created)
+				So(*entry.UpdatedAt, ShouldEqual, created)
+				stored, err := redirects.ParseEntry(values["redirect:/old"])
+				So(err, ShouldBeNil)
+				So(stored.To, ShouldEqual, "/new")
+				So(stored.Author, ShouldEqual, "alice")
+				So(stored.Ticket, ShouldEqual, "DIS-123")
+				So(stored.Source, ShouldEqual, "admin")
+			})
+
+			Convey("And the change is appended to its history in the same transaction", func() {
+				commands := redisMock.TransactionCalls()[0].Commands
+				So(commands, ShouldHaveLength, 3)
+				So(commands[0][:2], ShouldResemble, clients.Command{"SET", "redirect:/old"})
+				So(commands[1][:2], ShouldResemble, clients.Command{"RPUSH", "redirect:history:{redirect:/old}"})
+				So(commands[2], ShouldResemble, clients.Command{"LTRIM", "redirect:history:{redirect:/old}", -redirects.HistoryLimit, -1})
+
+				history, err := store.History(ctx, "/old")
+				So(err, ShouldBeNil)
+				So(history, ShouldHaveLength, 1)
+				So(history[0].Action, ShouldEqual, redirects.ActionSet)
+				So(history[0].At, ShouldEqual, created)
+				So(history[0].Author, ShouldEqual, "alice")
+				So(history[0].Entry.To, ShouldEqual, "/new")
+			})
+
+			Convey("And when it is updated", func() {
+				entry, err := store.Set(ctx, "/old", redirects.Entry{
+					To:       "/newer",
+					Metadata: redirects.Metadata{Author: "bob"},
+				}, updated)
+				So(err, ShouldBeNil)
+
+				Convey("Then the time it was created is kept", func() {
+					So(*entry.CreatedAt, ShouldEqual, created)
+					So(*entry.UpdatedAt, ShouldEqual, updated)
+					So(entry.Author, ShouldEqual, "bob")
+				})
+
+				Convey("And both changes are in its history, oldest first", func() {
+					history, err := store.History(ctx, "/old")
+					So(err, ShouldBeNil)
+					So(history, ShouldHaveLength, 2)
+					So(history[0].Entry.To, ShouldEqual, "/new")
+					So(history[1].Entry.To, ShouldEqual, "/newer")
+					So(history[1].Author, ShouldEqual, "bob")
+				})
+			})
+
+			Convey("And when it is deleted", func() {
+				deleted, err := store.Delete(ctx, "/old", redirects.Metadata{Author: "bob", Ticket: "DIS-456"}, updated)
+				So(err, ShouldBeNil)
+
+				Convey("Then it is removed but its history is kept", func() {
+					So(deleted, ShouldBeTrue)
+					So(values, ShouldNotContainKey, "redirect:/old")
+					history, err := store.History(ctx, "/old")
+					So(err, ShouldBeNil)
+					So(history, ShouldHaveLength, 2)
+					So(history[1].Action, ShouldEqual, redirects.ActionDelete)
+					So(history[1].Ticket, ShouldEqual, "DIS-456")
+					So(history[1].Entry, ShouldBeNil)
+				})
+			})
+		})
+
+		Convey("When a redirect that doesn't exist is deleted", func() {
+			deleted, err := store.Delete(ctx, "/missing", redirects.Metadata{Author: "bob"}, updated)
+
+			Convey("Then nothing is deleted or recorded", func() {
+				So(err, ShouldBeNil)
+				So(deleted, ShouldBeFalse)
+				So(redisMock.TransactionCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a redirect is changed more times than its history keeps", func() {
+			for i := range redirects.HistoryLimit + 1 {
+				_, err := store.Set(ctx, "/old", redirects.Entry{To: "/new", Metadata: redirects.Metadata{Author: "alice"}},
+					created.Add(time.Duration(i)*time.Minute))
+				So(err, ShouldBeNil)
+			}
+
+			Convey("Then only the latest changes are kept", func() {
+				history, err := store.History(ctx, "/old")
+				So(err, ShouldBeNil)
+				So(history, ShouldHaveLength, redirects.HistoryLimit)
+				So(history[0].At, ShouldEqual, created.Add(time.Minute))
+			})
+		})
+
+		Convey("When a redirect has never been changed", func() {
+			history, err := store.History(ctx, "/never")
+
+			Convey("Then its history is empty", func() {
+				So(err, ShouldBeNil)
+				So(history, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When an invalid redirect is set", func() {
+			_, pathErr := store.Set(ctx, "old", redirects.Entry{To: "/new"}, created)
+			_, targetErr := store.Set(ctx, "/old", redirects.Entry{}, created)
+
+			Convey("Then it is rejected", func() {
+				So(pathErr, ShouldEqual, redirects.ErrInvalidPath)
+				So(targetErr, ShouldEqual, redirects.ErrNoTarget)
+				So(values, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a redirect with a hash tag in its path is set or deleted", func() {
+			_, setErr := store.Set(ctx, "/{old}", redirects.Entry{To: "/new"}, created)
+			_, deleteErr := store.Delete(ctx, "/{old}", redirects.Metadata{Author: "bob"}, updated)
+
+			Convey("Then it is rejected, as its history could be in another cluster slot", func() {
+				So(setErr, ShouldEqual, redirects.ErrInvalidPath)
+				So(deleteErr, ShouldEqual, redirects.ErrInvalidPath)
+				So(redisMock.TransactionCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When Redis can't be written to", func() {
+			redisMock.TransactionFunc = func(ctx context.Context, commands ...clients.Command) error {
+				return errors.New("READONLY")
+			}
+			_, err := store.Set(ctx, "/old", redirects.Entry{To: "/new"}, created)
+
+			Convey("Then the error is returned", func() {
+				So(err, ShouldBeError, "READONLY")
+			})
+		})
+	})
+}
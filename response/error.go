@@ -0,0 +1,97 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	_ "embed" // required for the default error page template
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+//go:embed templates/error.html
+var defaultErrorTemplate string
+
+// ErrorPageData is the data made available to the error page template
+type ErrorPageData struct {
+	StatusCode int
+	Title      string
+	Message    string
+}
+
+// jsonError is the body written when the client prefers a JSON error
+type jsonError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorPage renders error responses as an ONS branded HTML page or JSON, depending on the request's Accept header
+type ErrorPage struct {
+	tmpl *template.Template
+}
+
+// NewErrorPage creates an ErrorPage from the HTML template at templatePath, or from the default template if the path is empty
+func NewErrorPage(templatePath string) (*ErrorPage, error) {
+	text := defaultErrorTemplate
+	if templatePath != "" {
+		b, err := os.ReadFile(templatePath) //nolint:gosec // the path is provided by trusted configuration
+		if err != nil {
+			return nil, fmt.Errorf("failed to read error page template: %w", err)
+		}
+		text = string(b)
+	}
+
+	tmpl, err := template.New("error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse error page template: %w", err)
+	}
+
+	return &ErrorPage{tmpl: tmpl}, nil
+}
+
+// Write writes an error response with the given status code and message
+func (p *ErrorPage) Write(ctx context.Context, w http.ResponseWriter, req *http.Request, statusCode int, message string) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if prefersJSON(req) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(statusCode)
+		if err := json.NewEncoder(w).Encode(jsonError{Code: statusCode, Message: message}); err != nil {
+			log.Error(ctx, "error writing JSON error response", err)
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	data := ErrorPageData{
+		StatusCode: statusCode,
+		Title:      http.StatusText(statusCode),
+		Message:    message,
+	}
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		log.Error(ctx, "error rendering error page template", err)
+		http.Error(w, message, statusCode)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Error(ctx, "error writing error page", err)
+	}
+}
+
+// prefersJSON reports whether the request asks for JSON rather than HTML
+func prefersJSON(req *http.Request) bool {
+	accept := req.Header.Get("Accept")
+	if strings.Contains(accept, "text/html") {
+		return false
+	}
+	return strings.Contains(accept, "application/json") || strings.Contains(accept, "+json")
+}
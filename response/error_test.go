@@ -0,0 +1,76 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrorPage(t *testing.T) {
+	Convey("Given the default error page", t, func() {
+		page, err := NewErrorPage("")
+		So(err, ShouldBeNil)
+
+		Convey("When a browser request receives an error", func() {
+			req := httptest.NewRequest(http.MethodGet, "/economy", http.NoBody)
+			req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+			w := httptest.NewRecorder()
+			page.Write(context.Background(), w, req, http.StatusBadGateway, "The service is temporarily unavailable.")
+
+			Convey("Then a branded HTML page is returned with the status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadGateway)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+				So(w.Body.String(), ShouldContainSubstring, "Office for National Statistics")
+				So(w.Body.String(), ShouldContainSubstring, "The service is temporarily unavailable.")
+			})
+		})
+
+		Convey("When a JSON request receives an error", func() {
+			req := httptest.NewRequest(http.MethodGet, "/economy", http.NoBody)
+			req.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+			page.Write(context.Background(), w, req, http.StatusGatewayTimeout, "The service took too long to respond.")
+
+			Convey("Then a JSON error is returned with the status code", func() {
+				So(w.Code, ShouldEqual, http.StatusGatewayTimeout)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
+
+				var body jsonError
+				So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+				So(body, ShouldResemble, jsonError{Code: http.StatusGatewayTimeout, Message: "The service took too long to respond."})
+			})
+		})
+	})
+
+	Convey("Given a custom error page template", t, func() {
+		path := filepath.Join(t.TempDir(), "error.html")
+		So(os.WriteFile(path, []byte("<p>{{.StatusCode}}: {{.Message}}</p>"), 0o600), ShouldBeNil)
+
+		page, err := NewErrorPage(path)
+		So(err, ShouldBeNil)
+
+		Convey("When an error is written", func() {
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			w := httptest.NewRecorder()
+			page.Write(context.Background(), w, req, http.StatusBadGateway, "oops")
+
+			Convey("Then the custom template is used", func() {
+				So(w.Body.String(), ShouldEqual, "<p>502: oops</p>")
+			})
+		})
+	})
+
+	Convey("Given a template path that does not exist", t, func() {
+		_, err := NewErrorPage(filepath.Join(t.TempDir(), "missing.html"))
+
+		Convey("Then an error is returned", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
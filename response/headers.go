@@ -0,0 +1,52 @@
+package response
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+)
+
+// HeaderPolicyEngine applies the configured response header policies to responses
+type HeaderPolicyEngine struct {
+	policies config.HeaderPolicies
+}
+
+// NewHeaderPolicyEngine creates a HeaderPolicyEngine for the given policies
+func NewHeaderPolicyEngine(policies config.HeaderPolicies) *HeaderPolicyEngine {
+	return &HeaderPolicyEngine{policies: policies}
+}
+
+// Apply modifies header according to every policy whose path prefix matches path. Policies are applied in
+// the order they are configured, so a general policy for "/" can be refined by more specific ones after it.
+// Within a policy, headers are removed first, then defaults are added and finally values are set.
+func (e *HeaderPolicyEngine) Apply(path string, header http.Header) {
+	for i := range e.policies {
+		policy := &e.policies[i]
+		if !strings.HasPrefix(path, policy.PathPrefix) {
+			continue
+		}
+
+		for _, name := range policy.Remove {
+			header.Del(name)
+		}
+		for name, value := range policy.Default {
+			if header.Get(name) == "" {
+				header.Set(name, value)
+			}
+		}
+		for name, value := range policy.Set {
+			header.Set(name, value)
+		}
+	}
+}
+
+// ModifyResponse applies the header policies to an upstream response. It is intended to be used as
+// httputil.ReverseProxy.ModifyResponse.
+func (e *HeaderPolicyEngine) ModifyResponse(resp *http.Response) error {
+	if len(e.policies) == 0 || resp.Request == nil {
+		return nil
+	}
+	e.Apply(resp.Request.URL.Path, resp.Header)
+	return nil
+}
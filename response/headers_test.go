@@ -0,0 +1,83 @@
+package response
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHeaderPolicyEngine(t *testing.T) {
+	Convey("Given a general security policy and a more specific cache policy", t, func() {
+		engine := NewHeaderPolicyEngine(config.HeaderPolicies{
+			{
+				PathPrefix: "/",
+				Set: map[string]string{
+					"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+					"X-Content-Type-Options":    "nosniff",
+				},
+				Default: map[string]string{"Cache-Control": "max-age=60"},
+				Remove:  []string{"Server", "X-Powered-By"},
+			},
+			{
+				PathPrefix: "/releases/",
+				Set:        map[string]string{"Cache-Control": "no-cache"},
+			},
+		})
+
+		upstreamHeaders := func() http.Header {
+			return http.Header{
+				"Server":       {"Apache"},
+				"X-Powered-By": {"PHP"},
+				"Content-Type": {"text/html"},
+			}
+		}
+
+		Convey("When a response for a general path is modified", func() {
+			header := upstreamHeaders()
+			engine.Apply("/economy", header)
+
+			Convey("Then security headers are added, leaky headers removed and the default cache header set", func() {
+				So(header.Get("Strict-Transport-Security"), ShouldEqual, "max-age=31536000; includeSubDomains")
+				So(header.Get("X-Content-Type-Options"), ShouldEqual, "nosniff")
+				So(header.Get("Cache-Control"), ShouldEqual, "max-age=60")
+				So(header.Get("Server"), ShouldBeEmpty)
+				So(header.Get("X-Powered-By"), ShouldBeEmpty)
+				So(header.Get("Content-Type"), ShouldEqual, "text/html")
+			})
+		})
+
+		Convey("When a response already has a cache header", func() {
+			header := upstreamHeaders()
+			header.Set("Cache-Control", "max-age=900")
+			engine.Apply("/economy", header)
+
+			Convey("Then the default does not override it", func() {
+				So(header.Get("Cache-Control"), ShouldEqual, "max-age=900")
+			})
+		})
+
+		Convey("When a response for a more specific path is modified", func() {
+			header := upstreamHeaders()
+			engine.Apply("/releases/some-release", header)
+
+			Convey("Then both policies are applied in order", func() {
+				So(header.Get("X-Content-Type-Options"), ShouldEqual, "nosniff")
+				So(header.Get("Cache-Control"), ShouldEqual, "no-cache")
+			})
+		})
+	})
+
+	Convey("Given the policy is decoded from its JSON configuration", t, func() {
+		var policies config.HeaderPolicies
+		err := policies.Decode(`[{"path_prefix": "/", "remove": ["Server"]}]`)
+		So(err, ShouldBeNil)
+
+		Convey("Then the engine applies it", func() {
+			header := http.Header{"Server": {"nginx"}}
+			NewHeaderPolicyEngine(policies).Apply("/", header)
+			So(header.Get("Server"), ShouldBeEmpty)
+		})
+	})
+}
@@ -10,6 +10,7 @@ import (
 )
 
 func WriteResponse(ctx context.Context, w http.ResponseWriter, serviceResponse *http.Response, req *http.Request, cfg *config.Config) {
+	NewHeaderPolicyEngine(cfg.ResponseHeaderPolicies).Apply(req.URL.Path, serviceResponse.Header)
 	writeUnmodifiedResponse(ctx, w, serviceResponse)
 }
 
@@ -3,7 +3,11 @@ package service
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/ONSdigital/dis-redirect-proxy/clients"
 	"github.com/ONSdigital/dis-redirect-proxy/config"
@@ -11,6 +15,8 @@ import (
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
 	dphttp "github.com/ONSdigital/dp-net/v3/http"
 	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
 )
 
 // ExternalServiceList holds the initialiser and initialisation state of external services.
@@ -74,35 +80,253 @@ var GetRedisClient = func(ctx context.Context, cfg *config.Config) (clients.Redi
 		ClusterName: cfg.RedisClusterName,
 		Region:      cfg.RedisRegion,
 		Service:     cfg.RedisService,
-		Username:    cfg.RedisUsername,
+	}
+	// dis-redis only accepts a username for IAM authentication, which needs the region; without it the username
+	// is an ACL user that authenticates with a password
+	if cfg.RedisRegion != "" {
+		clientCfg.Username = cfg.RedisUsername
 	}
 
 	if cfg.RedisSecProtocol == config.RedisTLSProtocol {
 		log.Info(ctx, "redis TLS protocol specified, initializing dis-redis client with TLS")
-		clientCfg.TLSConfig = &tls.Config{
-			MinVersion:         tls.VersionTLS12,
-			InsecureSkipVerify: false,
+		tlsConfig, err := redisTLSConfig(cfg)
+		if err != nil {
+			log.Error(ctx, "failed to load redis TLS files", err)
+			return nil, err
 		}
+		clientCfg.TLSConfig = tlsConfig
+	}
+
+	password, err := redisPassword(cfg)
+	if err != nil {
+		log.Error(ctx, "failed to read redis password", err)
+		return nil, err
 	}
 
-	var redisClient clients.Redis
-	var err error
+	// the go-redis client is created here, rather than by dis-redis, so that its pool and routing can be tuned
+	options, err := clientCfg.Get(ctx)
+	if err != nil {
+		log.Error(ctx, "failed to get dis-redis client config", err)
+		return nil, err
+	}
+	options.Username = cfg.RedisUsername
+	options.Password = password
 
+	var universal redis.UniversalClient
 	if cfg.RedisRegion != "" && cfg.RedisService != "" && cfg.RedisClusterName != "" {
-		redisClient, err = disRedis.NewClusterClient(ctx, clientCfg)
+		universal = redis.NewClusterClient(clusterOptions(cfg, options))
+	} else {
+		options.PoolSize = cfg.RedisPoolSize
+		options.MinIdleConns = cfg.RedisMinIdleConns
+		options.ReadTimeout = cfg.RedisReadTimeout
+		options.WriteTimeout = cfg.RedisWriteTimeout
+		universal = redis.NewClient(options)
+	}
+
+	return &redisClient{
+		Client:    disRedis.NewClientWithCustomClient(ctx, clientCfg, universal),
+		universal: universal,
+	}, nil
+}
+
+// clusterOptions returns the options for a client of the Redis cluster at options.Addr. The pool settings apply
+// to each node, and reads are routed to the nodes chosen by REDIS_READ_ROUTING; writes always go to a primary.
+func clusterOptions(cfg *config.Config, options *redis.Options) *redis.ClusterOptions {
+	return &redis.ClusterOptions{
+		Addrs:          []string{options.Addr},
+		Username:       options.Username,
+		Password:       options.Password,
+		PoolSize:       cfg.RedisPoolSize,
+		MinIdleConns:   cfg.RedisMinIdleConns,
+		ReadTimeout:    cfg.RedisReadTimeout,
+		WriteTimeout:   cfg.RedisWriteTimeout,
+		ReadOnly:       cfg.RedisReadRouting == config.RedisReadRoutingReplica,
+		RouteRandomly:  cfg.RedisReadRouting == config.RedisReadRoutingRandom,
+		RouteByLatency: cfg.RedisReadRouting == config.RedisReadRoutingLatency,
+		NewClient: func(opt *redis.Options) *redis.Client {
+			// each node authenticates with the cluster's IAM credentials, if they are used
+			node := *opt
+			node.CredentialsProviderContext = options.CredentialsProviderContext
+			node.TLSConfig = options.TLSConfig
+			return redis.NewClient(&node)
+		},
+	}
+}
+
+// redisPassword returns the password to authenticate with Redis. A password file is read every time a client is
+// created, so that a rotated password is picked up when the proxy reconnects.
+func redisPassword(cfg *config.Config) (string, error) {
+	if cfg.RedisPasswordFile == "" {
+		return cfg.RedisPassword, nil
+	}
+	password, err := os.ReadFile(cfg.RedisPasswordFile)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read REDIS_PASSWORD_FILE")
+	}
+	return strings.TrimRight(string(password), "\r\n"), nil
+}
+
+// redisTLSConfig returns the TLS config of the connection to Redis, which trusts the CA bundle in
+// REDIS_TLS_CA_FILE as well as the system's CAs, and presents the client certificate in REDIS_TLS_CERT_FILE
+func redisTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: false,
+	}
+
+	if cfg.RedisTLSCAFile != "" {
+		bundle, err := os.ReadFile(cfg.RedisTLSCAFile)
 		if err != nil {
-			log.Error(ctx, "failed to create dis-redis cluster client", err)
-			return nil, err
+			return nil, errors.Wrap(err, "failed to read REDIS_TLS_CA_FILE")
 		}
-	} else {
-		redisClient, err = disRedis.NewClient(ctx, clientCfg)
+		pool, err := x509.SystemCertPool()
 		if err != nil {
-			log.Error(ctx, "failed to create dis-redis client", err)
-			return nil, err
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.Errorf("REDIS_TLS_CA_FILE %q contains no PEM certificates", cfg.RedisTLSCAFile)
 		}
+		tlsConfig.RootCAs = pool
 	}
 
-	return redisClient, nil
+	if cfg.RedisTLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.RedisTLSCertFile, cfg.RedisTLSKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// redisClient is a dis-redis client that also reports the stats of its connection pool
+type redisClient struct {
+	*disRedis.Client
+	universal redis.UniversalClient
+}
+
+// PoolStats returns the stats of the client's connection pool, summed across the nodes of a cluster
+func (c *redisClient) PoolStats() *redis.PoolStats {
+	return c.universal.PoolStats()
+}
+
+// ScanKeys calls fn with each page of the keys matching matchPattern, without reading their values, requesting count
+// keys for each iteration of SCAN. SCAN only iterates the keys of the node it runs on, so every primary of a cluster
+// is scanned. fn is never called concurrently, and the scan stops at the first error it returns.
+func (c *redisClient) ScanKeys(ctx context.Context, matchPattern string, count int64, fn func(keys []string) error) error {
+	cluster, isCluster := c.universal.(*redis.ClusterClient)
+	if !isCluster {
+		return scanKeys(ctx, c.universal, matchPattern, count, fn)
+	}
+
+	// the primaries are scanned concurrently
+	var (
+		mu     sync.Mutex
+		failed error
+	)
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		return scanKeys(ctx, node, matchPattern, count, func(keys []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if failed == nil {
+				failed = fn(keys)
+			}
+			return failed
+		})
+	})
+}
+
+// scanKeys calls fn with each page of the keys on node matching matchPattern
+func scanKeys(ctx context.Context, node redis.Cmdable, matchPattern string, count int64, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := node.Scan(ctx, cursor, matchPattern, count).Result()
+		if err != nil {
+			return errors.Wrap(err, "error scanning keys")
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// RenameNX renames key to newKey, keeping its value and expiry, unless newKey already exists, and returns whether it
+// was renamed. A cluster can't rename a key to another slot, so there the key is copied with DUMP and RESTORE, which
+// also refuses to replace newKey, and then deleted instead.
+func (c *redisClient) RenameNX(ctx context.Context, key, newKey string) (bool, error) {
+	if _, isCluster := c.universal.(*redis.ClusterClient); !isCluster {
+		renamed, err := c.universal.RenameNX(ctx, key, newKey).Result()
+		if err != nil {
+			return false, errors.Wrapf(err, "error renaming key %s", key)
+		}
+		return renamed, nil
+	}
+
+	exists, err := c.universal.Exists(ctx, newKey).Result()
+	if err != nil {
+		return false, errors.Wrapf(err, "error checking key %s", newKey)
+	}
+	if exists > 0 {
+		return false, nil
+	}
+	dump, err := c.universal.Dump(ctx, key).Result()
+	if err != nil {
+		return false, errors.Wrapf(err, "error dumping key %s", key)
+	}
+	ttl, err := c.universal.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting expiry of key %s", key)
+	}
+	// a key without an expiry has a negative TTL, and is restored without one
+	if err := c.universal.Restore(ctx, newKey, max(ttl, 0), dump).Err(); err != nil {
+		// newKey was created after it was checked
+		if strings.HasPrefix(err.Error(), "BUSYKEY") {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "error restoring key %s", newKey)
+	}
+	if err := c.universal.Del(ctx, key).Err(); err != nil {
+		return false, errors.Wrapf(err, "error deleting key %s", key)
+	}
+	return true, nil
+}
+
+// GetList returns the elements of the list at key, which is empty if the key doesn't exist
+func (c *redisClient) GetList(ctx context.Context, key string) ([]string, error) {
+	values, err := c.universal.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting list %s", key)
+	}
+	return values, nil
+}
+
+// Transaction runs commands in a MULTI/EXEC transaction, so that they are applied together. A cluster runs a
+// transaction for each slot that the keys of the commands are in.
+func (c *redisClient) Transaction(ctx context.Context, commands ...clients.Command) error {
+	_, err := c.universal.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, command := range commands {
+			pipe.Do(ctx, command...)
+		}
+		return nil
+	})
+	return errors.Wrap(err, "error running transaction")
+}
+
+// RunScript runs a Lua script with EVALSHA, which Redis runs atomically. The script is only sent with EVAL if Redis
+// doesn't have it cached yet.
+func (c *redisClient) RunScript(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	result, err := script.Run(ctx, c.universal, keys, args...).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "error running script")
+	}
+	return result, nil
 }
 
 func (e *Init) DoGetRequestMiddleware() RequestMiddleware {
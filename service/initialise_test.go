@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGetRedisClient(t *testing.T) {
+	Convey("Given a config with Redis pool settings", t, func() {
+		cfg, err := config.Load()
+		So(err, ShouldBeNil)
+		cfg.RedisPoolSize = 20
+		cfg.RedisMinIdleConns = 5
+		cfg.RedisReadTimeout = time.Second
+		cfg.RedisWriteTimeout = 2 * time.Second
+
+		Convey("When a client is created for REDIS_ADDRESS", func() {
+			// the pool dials its idle connections in the background, so they are made to a server that accepts
+			// them, and are open before the client is closed
+			server := miniredis.RunT(t)
+			cfg.RedisAddress = server.Addr()
+			client, err := GetRedisClient(context.Background(), cfg)
+			So(err, ShouldBeNil)
+			pool := client.(*redisClient)
+			for deadline := time.Now().Add(time.Second); pool.PoolStats().IdleConns < 5 && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+			defer client.Close(context.Background())
+
+			Convey("Then its pool uses the settings", func() {
+				options := client.(*redisClient).universal.(*redis.Client).Options()
+				So(options.Addr, ShouldEqual, server.Addr())
+				So(options.PoolSize, ShouldEqual, 20)
+				So(options.MinIdleConns, ShouldEqual, 5)
+				So(options.ReadTimeout, ShouldEqual, time.Second)
+				So(options.WriteTimeout, ShouldEqual, 2*time.Second)
+			})
+
+			Convey("And it reports the stats of its pool, which keeps the minimum idle connections open", func() {
+				So(pool.PoolStats().IdleConns, ShouldEqual, 5)
+			})
+		})
+
+		Convey("When the options for a cluster are created", func() {
+			cfg.RedisReadRouting = config.RedisReadRoutingRandom
+			options := clusterOptions(cfg, &redis.Options{Addr: "cluster:6379", Username: "proxy"})
+
+			Convey("Then they use the pool settings and route reads to any node", func() {
+				So(options.Addrs, ShouldResemble, []string{"cluster:6379"})
+				So(options.Username, ShouldEqual, "proxy")
+				So(options.PoolSize, ShouldEqual, 20)
+				So(options.MinIdleConns, ShouldEqual, 5)
+				So(options.ReadTimeout, ShouldEqual, time.Second)
+				So(options.WriteTimeout, ShouldEqual, 2*time.Second)
+				So(options.RouteRandomly, ShouldBeTrue)
+				So(options.RouteByLatency, ShouldBeFalse)
+				So(options.ReadOnly, ShouldBeFalse)
+			})
+		})
+
+		Convey("When reads are routed to replicas", func() {
+			cfg.RedisReadRouting = config.RedisReadRoutingReplica
+			options := clusterOptions(cfg, &redis.Options{Addr: "cluster:6379"})
+
+			Convey("Then the cluster client reads from replicas", func() {
+				So(options.ReadOnly, ShouldBeTrue)
+				So(options.RouteRandomly, ShouldBeFalse)
+			})
+		})
+
+		Convey("When reads are routed to primaries", func() {
+			options := clusterOptions(cfg, &redis.Options{Addr: "cluster:6379"})
+
+			Convey("Then the cluster client only reads from primaries", func() {
+				So(options.ReadOnly, ShouldBeFalse)
+				So(options.RouteRandomly, ShouldBeFalse)
+				So(options.RouteByLatency, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestRedisAuthentication(t *testing.T) {
+	Convey("Given a config for Redis", t, func() {
+		cfg, err := config.Load()
+		So(err, ShouldBeNil)
+		dir := t.TempDir()
+
+		Convey("When a password is set", func() {
+			cfg.RedisUsername = "proxy"
+			cfg.RedisPassword = "secret"
+			client, err := GetRedisClient(context.Background(), cfg)
+			So(err, ShouldBeNil)
+			defer client.Close(context.Background())
+
+			Convey("Then the client authenticates with the ACL user and password", func() {
+				options := client.(*redisClient).universal.(*redis.Client).Options()
+				So(options.Username, ShouldEqual, "proxy")
+				So(options.Password, ShouldEqual, "secret")
+			})
+		})
+
+		Convey("When the password is read from a file", func() {
+			cfg.RedisPasswordFile = filepath.Join(dir, "password")
+			So(os.WriteFile(cfg.RedisPasswordFile, []byte("from-file\n"), 0o600), ShouldBeNil)
+
+			Convey("Then the trailing newline is removed", func() {
+				password, err := redisPassword(cfg)
+				So(err, ShouldBeNil)
+				So(password, ShouldEqual, "from-file")
+			})
+		})
+
+		Convey("When the password file doesn't exist", func() {
+			cfg.RedisPasswordFile = filepath.Join(dir, "missing")
+
+			Convey("Then the client can't be created", func() {
+				_, err := GetRedisClient(context.Background(), cfg)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "failed to read REDIS_PASSWORD_FILE")
+			})
+		})
+
+		Convey("When the password is set for a cluster", func() {
+			options := clusterOptions(cfg, &redis.Options{Addr: "cluster:6379", Password: "secret"})
+
+			Convey("Then every node authenticates with it", func() {
+				So(options.Password, ShouldEqual, "secret")
+			})
+		})
+
+		Convey("When TLS is used with a CA bundle and a client certificate", func() {
+			cfg.RedisSecProtocol = config.RedisTLSProtocol
+			cfg.RedisTLSCAFile, cfg.RedisTLSCertFile, cfg.RedisTLSKeyFile = writeCertificate(t, dir)
+			tlsConfig, err := redisTLSConfig(cfg)
+			So(err, ShouldBeNil)
+
+			Convey("Then the CA is trusted and the certificate is presented", func() {
+				So(tlsConfig.RootCAs, ShouldNotBeNil)
+				So(tlsConfig.Certificates, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the CA bundle contains no certificates", func() {
+			cfg.RedisTLSCAFile = filepath.Join(dir, "ca.pem")
+			So(os.WriteFile(cfg.RedisTLSCAFile, []byte("not a certificate"), 0o600), ShouldBeNil)
+
+			Convey("Then the TLS config can't be created", func() {
+				_, err := redisTLSConfig(cfg)
+				So(err, ShouldBeError, `REDIS_TLS_CA_FILE "`+cfg.RedisTLSCAFile+`" contains no PEM certificates`)
+			})
+		})
+	})
+}
+
+// writeCertificate writes a self-signed certificate and its key to dir, returning the paths of the CA bundle,
+// certificate and key, where the bundle and the certificate are the same file
+func writeCertificate(t *testing.T, dir string) (caFile, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, certFile, keyFile
+}
+
+func TestRedisClientKeys(t *testing.T) {
+	ctx := context.Background()
+	// newNode starts a Redis server that is stopped when the test ends
+	newNode := func() *miniredis.Miniredis {
+		return miniredis.RunT(t)
+	}
+
+	Convey("Given a client of a single Redis server", t, func() {
+		node := newNode()
+		universal := redis.NewClient(&redis.Options{Addr: node.Addr()})
+		Reset(func() { _ = universal.Close() })
+		client := &redisClient{universal: universal}
+
+		testRedisClientKeys(ctx, client)
+
+		Convey("When a key is renamed to a key that doesn't exist", func() {
+			So(universal.Set(ctx, "/people", "/people/new", time.Hour).Err(), ShouldBeNil)
+			renamed, err := client.RenameNX(ctx, "/people", "redirect:/people")
+
+			Convey("Then it is renamed, keeping its value and expiry", func() {
+				So(err, ShouldBeNil)
+				So(renamed, ShouldBeTrue)
+				So(node.Keys(), ShouldResemble, []string{"redirect:/people"})
+				So(universal.Get(ctx, "redirect:/people").Val(), ShouldEqual, "/people/new")
+				So(universal.PTTL(ctx, "redirect:/people").Val(), ShouldBeGreaterThan, 0)
+			})
+		})
+	})
+
+	Convey("Given a client of a Redis cluster with two primaries", t, func() {
+		nodes := []*miniredis.Miniredis{newNode(), newNode()}
+		universal := redis.NewClusterClient(&redis.ClusterOptions{
+			ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+				return []redis.ClusterSlot{
+					{Start: 0, End: 8191, Nodes: []redis.ClusterNode{{Addr: nodes[0].Addr()}}},
+					{Start: 8192, End: 16383, Nodes: []redis.ClusterNode{{Addr: nodes[1].Addr()}}},
+				}, nil
+			},
+		})
+		Reset(func() { _ = universal.Close() })
+		client := &redisClient{universal: universal}
+
+		// the test server doesn't support DUMP and RESTORE, so only a rename that is refused can be checked
+		testRedisClientKeys(ctx, client)
+
+		Convey("When keys are spread across the primaries", func() {
+			for i := range 20 {
+				So(universal.Set(ctx, fmt.Sprintf("/page/%d", i), "value", 0).Err(), ShouldBeNil)
+			}
+
+			Convey("Then both primaries hold some of them, and every key is scanned", func() {
+				So(nodes[0].Keys(), ShouldNotBeEmpty)
+				So(nodes[1].Keys(), ShouldNotBeEmpty)
+				var keys []string
+				So(client.ScanKeys(ctx, "/page/*", 2, func(page []string) error {
+					keys = append(keys, page...)
+					return nil
+				}), ShouldBeNil)
+				So(keys, ShouldHaveLength, 20)
+			})
+		})
+	})
+}
+
+// testRedisClientKeys checks scanning keys with client, and that a key is never renamed to a key that already exists
+func testRedisClientKeys(ctx context.Context, client *redisClient) {
+	Convey("When keys are scanned", func() {
+		So(client.universal.Set(ctx, "/economy", "/economy/new", 0).Err(), ShouldBeNil)
+		So(client.universal.Set(ctx, "/people", "/people/new", 0).Err(), ShouldBeNil)
+		So(client.universal.Set(ctx, "maintenance", "[]", 0).Err(), ShouldBeNil)
+		var keys []string
+		err := client.ScanKeys(ctx, "/*", 1, func(page []string) error {
+			keys = append(keys, page...)
+			return nil
+		})
+
+		Convey("Then only the matching keys are returned", func() {
+			So(err, ShouldBeNil)
+			So(keys, ShouldHaveLength, 2)
+			So(keys, ShouldContain, "/economy")
+			So(keys, ShouldContain, "/people")
+		})
+
+		Convey("And when a key is renamed to a key that already exists", func() {
+			So(client.universal.Set(ctx, "redirect:/economy", "/economy/newer", 0).Err(), ShouldBeNil)
+			renamed, err := client.RenameNX(ctx, "/economy", "redirect:/economy")
+
+			Convey("Then neither key is changed", func() {
+				So(err, ShouldBeNil)
+				So(renamed, ShouldBeFalse)
+				So(client.universal.Get(ctx, "redirect:/economy").Val(), ShouldEqual, "/economy/newer")
+				So(client.universal.Get(ctx, "/economy").Val(), ShouldEqual, "/economy/new")
+			})
+		})
+	})
+
+	Convey("When the function given the keys fails", func() {
+		So(client.universal.Set(ctx, "/economy", "/economy/new", 0).Err(), ShouldBeNil)
+		err := client.ScanKeys(ctx, "/*", 1, func(page []string) error {
+			return errors.New("READONLY")
+		})
+
+		Convey("Then the scan stops with its error", func() {
+			So(err, ShouldBeError, "READONLY")
+		})
+	})
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+)
+
+const (
+	// LivenessPath is the path of the endpoint that reports whether the process is alive
+	LivenessPath = "/livez"
+	// ReadinessPath is the path of the endpoint that reports whether the service is ready to take traffic
+	ReadinessPath = "/readyz"
+)
+
+// Livez reports that the process is alive. It never depends on Redis or the upstreams, so that the service
+// isn't restarted because a dependency is down.
+func (svc *Service) Livez(w http.ResponseWriter, _ *http.Request) {
+	writeProbe(w, http.StatusOK, "ok")
+}
+
+// Readyz reports whether the service is ready to take traffic. It isn't ready until its routes have been
+// built and the HTTP server started, once it has started shutting down, or while an essential dependency was
+// critical when it was last checked.
+func (svc *Service) Readyz(w http.ResponseWriter, _ *http.Request) {
+	if !svc.ready.Load() {
+		writeProbe(w, http.StatusServiceUnavailable, "not ready")
+		return
+	}
+	if name := svc.essentialChecks.critical(); name != "" {
+		writeProbe(w, http.StatusServiceUnavailable, name+" is unavailable")
+		return
+	}
+	writeProbe(w, http.StatusOK, "ok")
+}
+
+// essentialChecks records the state of the health checks of the dependencies that the service can't take
+// traffic without. The overall status of the health check is only recomputed when it is requested, so the
+// state of each check is read directly.
+type essentialChecks struct {
+	mu     sync.Mutex
+	names  []string
+	states map[string]*healthcheck.CheckState
+}
+
+// track returns checker, recording the state it updates as the state of the essential dependency name
+func (e *essentialChecks) track(name string, checker healthcheck.Checker) healthcheck.Checker {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.names = append(e.names, name)
+
+	return func(ctx context.Context, state *healthcheck.CheckState) error {
+		e.mu.Lock()
+		if e.states == nil {
+			e.states = map[string]*healthcheck.CheckState{}
+		}
+		e.states[name] = state
+		e.mu.Unlock()
+		return checker(ctx, state)
+	}
+}
+
+// critical returns the name of the first essential dependency that was critical when it was last checked, or ""
+// if none were. A dependency that hasn't been checked yet isn't critical.
+func (e *essentialChecks) critical() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, name := range e.names {
+		if state, ok := e.states[name]; ok && state.Status() == healthcheck.StatusCritical {
+			return name
+		}
+	}
+	return ""
+}
+
+// writeProbe writes the plain text response of a probe, which must never be cached
+func writeProbe(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write([]byte(message + "\n"))
+}
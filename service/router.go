@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/ONSdigital/dis-redirect-proxy/admin"
+	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/maintenance"
+	"github.com/ONSdigital/dis-redirect-proxy/middleware"
+	"github.com/ONSdigital/dis-redirect-proxy/proxy"
+	"github.com/ONSdigital/dis-redirect-proxy/ratelimit"
+	"github.com/ONSdigital/dis-redirect-proxy/redirects"
+	"github.com/ONSdigital/log.go/v2/log"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+)
+
+// routerHandler is the http.Handler given to the HTTP server. It passes each request to the current router,
+// which is replaced when the config is reloaded. Requests that are in flight when the router is replaced
+// finish on the router that they started on.
+type routerHandler struct {
+	router atomic.Pointer[mux.Router]
+}
+
+// ServeHTTP implements http.Handler
+func (h *routerHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.router.Load().ServeHTTP(w, req)
+}
+
+// routes holds a router and the components built with it from a single config
+type routes struct {
+	router      *mux.Router
+	proxy       *proxy.Proxy
+	admin       *admin.API
+	maintenance *maintenance.Mode
+}
+
+// stop stops the background polling of the components, once the routes are no longer in use
+func (rt *routes) stop() {
+	if rt.maintenance != nil {
+		rt.maintenance.Stop()
+	}
+	if rt.proxy != nil {
+		rt.proxy.Flags.Stop()
+	}
+}
+
+// buildRouter builds a router, and the components that serve it, from cfg. The Redis client and health
+// check are shared by every router, so they must already have been created.
+func (svc *Service) buildRouter(ctx context.Context, cfg *config.Config) (rt *routes, err error) {
+	rt = &routes{router: mux.NewRouter()}
+	r := rt.router
+
+	// stop anything already started if the router can't be completed
+	defer func() {
+		if err != nil {
+			rt.stop()
+		}
+	}()
+
+	if cfg.OtelEnabled {
+		// TODO: Any middleware will require 'otelhttp.NewMiddleware(cfg.OTServiceName),' included for Open Telemetry
+		r.Use(otelmux.Middleware(cfg.OTServiceName))
+	}
+	r.Use(svc.ServiceList.Init.DoGetRequestMiddleware().GetMiddlewareFunction())
+	r.Use(middleware.RequestID())
+	if cfg.CompressionEnabled {
+		r.Use(middleware.Compress(cfg.CompressionMinSize, cfg.CompressionContentTypes))
+	}
+
+	// the rate limiter must be registered before the proxy's redirect middleware, so that limited clients
+	// can't reach Redis or the upstreams
+	if cfg.RateLimitEnabled {
+		limiter, err := ratelimit.New(cfg, svc.ServiceList.RedisCli)
+		if err != nil {
+			log.Error(ctx, "failed to setup rate limiter", err)
+			return nil, err
+		}
+		r.Use(limiter.Middleware())
+	}
+
+	// maintenance mode must also come before the redirect middleware, but never blocks the health check, the
+	// probes or the admin API used to switch it off
+	var maintenanceSwitch admin.MaintenanceSwitch
+	if cfg.MaintenanceEnabled {
+		rt.maintenance, err = maintenance.New(cfg, svc.ServiceList.RedisCli)
+		if err != nil {
+			log.Error(ctx, "failed to setup maintenance mode", err)
+			return nil, err
+		}
+		rt.maintenance.Start(ctx)
+		r.Use(rt.maintenance.Middleware("/health", LivenessPath, ReadinessPath, admin.PathPrefix))
+		maintenanceSwitch = rt.maintenance
+	}
+
+	r.StrictSlash(true).Path("/health").HandlerFunc(svc.HealthCheck.Handler)
+	r.Path(LivenessPath).HandlerFunc(svc.Livez)
+	r.Path(ReadinessPath).HandlerFunc(svc.Readyz)
+	// the admin subrouter must be created before the proxy's catch-all route, but its endpoints depend on the proxy
+	adminRouter := r.PathPrefix(admin.PathPrefix).Subrouter()
+	// proxy adds a catch-all route, so any other routes added after that one will never be reachable.
+	rt.proxy, err = proxy.Setup(ctx, r, cfg, svc.ServiceList.RedisCli)
+	if err != nil {
+		log.Error(ctx, "failed to setup proxy", err)
+		return nil, err
+	}
+
+	var cachePurger admin.CachePurger
+	if rt.proxy.Cache != nil {
+		cachePurger = rt.proxy.Cache
+	}
+	// redirects can be managed whenever the proxy may look them up
+	var redirectStore admin.RedirectStore
+	if cfg.EnableRedirects || cfg.FeatureFlagsSource != "" {
+		redirectStore = redirects.NewStore(svc.ServiceList.RedisCli, cfg.RedirectKeyPrefix)
+	}
+	rt.admin = admin.Setup(ctx, adminRouter, cfg, cachePurger, maintenanceSwitch, redirectStore, svc)
+
+	return rt, nil
+}
+
+// Reload reads the config again and builds a new router from it, which replaces the current router once it
+// is complete. If the config can't be read, or the router can't be built, the current router is kept.
+// Settings used to create the HTTP server, Redis client, health check and telemetry only take effect on
+// restart.
+func (svc *Service) Reload(ctx context.Context) error {
+	svc.reloadMu.Lock()
+	defer svc.reloadMu.Unlock()
+
+	if svc.closing {
+		return errors.New("service is shutting down")
+	}
+
+	log.Info(ctx, "reloading config")
+	cfg, err := config.Load()
+	if err != nil {
+		log.Error(ctx, "failed to reload config, keeping current config", err)
+		return errors.Wrap(err, "failed to read config")
+	}
+
+	// the router's components keep polling Redis with the context they are built with, so it must outlive the
+	// reload, which may have been requested through the admin API
+	rt, err := svc.buildRouter(context.WithoutCancel(ctx), cfg)
+	if err != nil {
+		log.Error(ctx, "failed to reload config, keeping current config", err)
+		return errors.Wrap(err, "failed to build router")
+	}
+
+	if changed := restartRequired(svc.Config, cfg); len(changed) > 0 {
+		log.Warn(ctx, "config changes that require a restart have been ignored", log.Data{"settings": changed})
+	}
+
+	previous := svc.currentRoutes()
+	svc.handler.router.Store(rt.router)
+	svc.setRoutes(cfg, rt)
+	previous.stop()
+
+	log.Info(ctx, "config reloaded", log.Data{"config": cfg})
+	return nil
+}
+
+// currentRoutes returns the current router and its components
+func (svc *Service) currentRoutes() *routes {
+	return &routes{router: svc.Router, proxy: svc.Proxy, admin: svc.Admin, maintenance: svc.Maintenance}
+}
+
+// setRoutes makes cfg and rt the current config and router
+func (svc *Service) setRoutes(cfg *config.Config, rt *routes) {
+	svc.Config = cfg
+	svc.Router = rt.router
+	svc.Proxy = rt.proxy
+	svc.Admin = rt.admin
+	svc.Maintenance = rt.maintenance
+}
+
+// restartRequired returns the environment variables of the settings that differ between previous and next,
+// but are only read when the service starts
+func restartRequired(previous, next *config.Config) []string {
+	var changed []string
+	check := func(name string, equal bool) {
+		if !equal {
+			changed = append(changed, name)
+		}
+	}
+
+	check("BIND_ADDR", previous.BindAddr == next.BindAddr)
+	check("HEALTHCHECK_INTERVAL", previous.HealthCheckInterval == next.HealthCheckInterval)
+	check("HEALTHCHECK_CRITICAL_TIMEOUT", previous.HealthCheckCriticalTimeout == next.HealthCheckCriticalTimeout)
+	check("HEALTHCHECK_LEGACY_PATH", previous.HealthCheckLegacyPath == next.HealthCheckLegacyPath)
+	check("HEALTHCHECK_LEGACY_SEVERITY", previous.HealthCheckLegacySeverity == next.HealthCheckLegacySeverity)
+	check("HEALTHCHECK_LEGACY_STATUS", previous.HealthCheckLegacyStatus == next.HealthCheckLegacyStatus)
+	check("HEALTHCHECK_UPSTREAM_TIMEOUT", previous.HealthCheckUpstreamTimeout == next.HealthCheckUpstreamTimeout)
+	check("HEALTHCHECK_UPSTREAMS", previous.HealthCheckUpstreams == next.HealthCheckUpstreams)
+	check("HEALTHCHECK_WAGTAIL_PATH", previous.HealthCheckWagtailPath == next.HealthCheckWagtailPath)
+	check("HEALTHCHECK_WAGTAIL_SEVERITY", previous.HealthCheckWagtailSeverity == next.HealthCheckWagtailSeverity)
+	check("HEALTHCHECK_WAGTAIL_STATUS", previous.HealthCheckWagtailStatus == next.HealthCheckWagtailStatus)
+	check("OTEL_BATCH_TIMEOUT", previous.OTBatchTimeout == next.OTBatchTimeout)
+	check("OTEL_EXPORTER_OTLP_ENDPOINT", previous.OTExporterOTLPEndpoint == next.OTExporterOTLPEndpoint)
+	check("OTEL_SERVICE_NAME", previous.OTServiceName == next.OTServiceName)
+	check("OTEL_ENABLED", previous.OtelEnabled == next.OtelEnabled)
+	check("REDIS_ADDRESS", previous.RedisAddress == next.RedisAddress)
+	check("REDIS_CLUSTER_NAME", previous.RedisClusterName == next.RedisClusterName)
+	check("REDIS_CONNECT_BACKOFF", previous.RedisConnectBackoff == next.RedisConnectBackoff)
+	check("REDIS_CONNECT_MAX_BACKOFF", previous.RedisConnectMaxBackoff == next.RedisConnectMaxBackoff)
+	check("REDIS_MIN_IDLE_CONNS", previous.RedisMinIdleConns == next.RedisMinIdleConns)
+	check("REDIS_PASSWORD", previous.RedisPassword == next.RedisPassword)
+	check("REDIS_PASSWORD_FILE", previous.RedisPasswordFile == next.RedisPasswordFile)
+	check("REDIS_POOL_SIZE", previous.RedisPoolSize == next.RedisPoolSize)
+	check("REDIS_READ_ROUTING", previous.RedisReadRouting == next.RedisReadRouting)
+	check("REDIS_READ_TIMEOUT", previous.RedisReadTimeout == next.RedisReadTimeout)
+	check("REDIS_REGION", previous.RedisRegion == next.RedisRegion)
+	check("REDIS_SEC_PROTO", previous.RedisSecProtocol == next.RedisSecProtocol)
+	check("REDIS_SERVICE", previous.RedisService == next.RedisService)
+	check("REDIS_TLS_CA_FILE", previous.RedisTLSCAFile == next.RedisTLSCAFile)
+	check("REDIS_TLS_CERT_FILE", previous.RedisTLSCertFile == next.RedisTLSCertFile)
+	check("REDIS_TLS_KEY_FILE", previous.RedisTLSKeyFile == next.RedisTLSKeyFile)
+	check("REDIS_USERNAME", previous.RedisUsername == next.RedisUsername)
+	check("REDIS_WRITE_TIMEOUT", previous.RedisWriteTimeout == next.RedisWriteTimeout)
+	return changed
+}
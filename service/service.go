@@ -2,14 +2,20 @@ package service
 
 import (
 	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ONSdigital/dis-redirect-proxy/admin"
 	"github.com/ONSdigital/dis-redirect-proxy/clients"
 	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/maintenance"
+	"github.com/ONSdigital/dis-redirect-proxy/metrics"
 	"github.com/ONSdigital/dis-redirect-proxy/proxy"
 	"github.com/ONSdigital/log.go/v2/log"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
@@ -19,39 +25,77 @@ type Service struct {
 	Server      HTTPServer
 	Router      *mux.Router
 	Proxy       *proxy.Proxy
+	Admin       *admin.API
+	Maintenance *maintenance.Mode
 	ServiceList *ExternalServiceList
 	HealthCheck HealthChecker
+	// ShutdownTelemetry, if set, flushes and stops the telemetry exporters. It is called last when the service
+	// is closed, so that the spans and metrics of the final requests are exported.
+	ShutdownTelemetry func(ctx context.Context) error
+
+	// handler passes requests to Router, which Reload replaces
+	handler  *routerHandler
+	reloadMu sync.Mutex
+	closing  bool
+	// ready is reported by the readiness endpoint, and is only set while the service can take traffic
+	ready atomic.Bool
+	// essentialChecks are the health checks that the readiness endpoint reports
+	essentialChecks essentialChecks
+}
+
+// ListenError is sent on the service errors channel when the HTTP server can't listen on its bind address,
+// which means that the service failed to start rather than failed while running
+type ListenError struct {
+	Err error
+}
+
+func (e *ListenError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ListenError) Unwrap() error {
+	return e.Err
 }
 
 // Run the service
-func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceList, buildTime, gitCommit, version string, svcErrors chan error) (*Service, error) {
+func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceList, buildTime, gitCommit, version string, svcErrors chan error) (svc *Service, err error) {
 	log.Info(ctx, "running service redirect proxy")
 
 	log.Info(ctx, "using service configuration", log.Data{"config": cfg})
-	r := mux.NewRouter()
+	handler := &routerHandler{}
 
 	var s HTTPServer
 
 	if cfg.OtelEnabled {
-		otelHandler := otelhttp.NewHandler(r, "/")
-		r.Use(otelmux.Middleware(cfg.OTServiceName))
-		// TODO: Any middleware will require 'otelhttp.NewMiddleware(cfg.OTServiceName),' included for Open Telemetry
+		otelHandler := otelhttp.NewHandler(handler, "/")
 		s = serviceList.GetHTTPServer(cfg.BindAddr, otelHandler)
 	} else {
-		s = serviceList.GetHTTPServer(cfg.BindAddr, r)
+		s = serviceList.GetHTTPServer(cfg.BindAddr, handler)
 	}
 
-	r.Use(serviceList.Init.DoGetRequestMiddleware().GetMiddlewareFunction())
-
 	// TODO: Add other(s) to serviceList here
 
-	// Get RedisClient client
-	var redisErr error
-	serviceList.RedisCli, redisErr = GetRedisClient(ctx, cfg)
+	// Redis is only connected to if a feature uses it. If it is unavailable the proxy starts without it, with
+	// redirects disabled, and keeps trying to connect in the background.
+	if cfg.RedisRequired() {
+		redisConn := clients.NewRedisConnection(func(ctx context.Context) (clients.Redis, error) {
+			return GetRedisClient(ctx, cfg)
+		}, cfg.RedisConnectBackoff, cfg.RedisConnectMaxBackoff)
+		redisConn.Start(ctx)
+		serviceList.RedisCli = redisConn
+
+		// stop connecting to Redis if the service can't be started
+		defer func() {
+			if err != nil {
+				if closeErr := redisConn.Close(ctx); closeErr != nil {
+					log.Error(ctx, "failed to close redis client", closeErr)
+				}
+			}
+		}()
 
-	if redisErr != nil {
-		log.Fatal(ctx, "failed to initialise redis", redisErr)
-		return nil, redisErr
+		if err := metrics.ObserveRedisPool(redisConn.PoolStats); err != nil {
+			log.Error(ctx, "failed to observe redis connection pool metrics", err)
+		}
 	}
 
 	hc, err := serviceList.GetHealthCheck(cfg, buildTime, gitCommit, version)
@@ -61,38 +105,66 @@ func Run(ctx context.Context, cfg *config.Config, serviceList *ExternalServiceLi
 		return nil, err
 	}
 
-	if err := registerCheckers(ctx, cfg, hc, serviceList.RedisCli); err != nil {
+	svc = &Service{
+		HealthCheck: hc,
+		ServiceList: serviceList,
+		Server:      s,
+		handler:     handler,
+	}
+
+	if err = registerCheckers(ctx, cfg, hc, serviceList.RedisCli, &svc.essentialChecks); err != nil {
 		return nil, errors.Wrap(err, "unable to register checkers")
 	}
 
-	r.StrictSlash(true).Path("/health").HandlerFunc(hc.Handler)
-	// proxy adds a catch-all route, so any other routes added after that one will never be reachable.
-	p, err := proxy.Setup(ctx, r, cfg, serviceList.RedisCli)
+	rt, err := svc.buildRouter(ctx, cfg)
 	if err != nil {
-		log.Error(ctx, "failed to setup proxy", err)
 		return nil, err
 	}
+	handler.router.Store(rt.router)
+	svc.setRoutes(cfg, rt)
+
 	hc.Start(ctx)
 
 	// Run the http server in a new go-routine
 	go func() {
 		if err := s.ListenAndServe(); err != nil {
-			svcErrors <- errors.Wrap(err, "failure in http listen and serve")
+			err = errors.Wrap(err, "failure in http listen and serve")
+			var opErr *net.OpError
+			if errors.As(err, &opErr) && opErr.Op == "listen" {
+				err = &ListenError{Err: err}
+			}
+			svcErrors <- err
 		}
 	}()
+	svc.ready.Store(true)
 
-	return &Service{
-		Config:      cfg,
-		Router:      r,
-		Proxy:       p,
-		HealthCheck: hc,
-		ServiceList: serviceList,
-		Server:      s,
-	}, nil
+	return svc, nil
 }
 
 // Close gracefully shuts the service down in the required order, with timeout
 func (svc *Service) Close(ctx context.Context) error {
+	return svc.close(ctx, true)
+}
+
+// CloseAfterError shuts the service down like Close, but without draining traffic first, as the HTTP server
+// has already failed and can't serve it
+func (svc *Service) CloseAfterError(ctx context.Context) error {
+	return svc.close(ctx, false)
+}
+
+// close shuts the service down, waiting for the graceful shutdown drain period first if drain is true
+func (svc *Service) close(ctx context.Context, drain bool) error {
+	// the config and router must not be replaced while the service is shutting down
+	svc.reloadMu.Lock()
+	svc.closing = true
+	svc.reloadMu.Unlock()
+
+	// report that the service isn't ready, but keep serving while load balancers stop sending traffic to it
+	svc.ready.Store(false)
+	if drain {
+		svc.drain(ctx)
+	}
+
 	timeout := svc.Config.GracefulShutdownTimeout
 	log.Info(ctx, "commencing graceful shutdown", log.Data{"graceful_shutdown_timeout": timeout})
 	ctx, cancel := context.WithTimeout(ctx, timeout)
@@ -114,7 +186,24 @@ func (svc *Service) Close(ctx context.Context) error {
 			hasShutdownError = true
 		}
 
-		// TODO: Close other dependencies, in the expected order
+		// stop polling for maintenance windows and feature flags
+		svc.currentRoutes().stop()
+
+		// close the Redis client once nothing is left that uses it
+		if svc.ServiceList.RedisCli != nil {
+			if err := svc.ServiceList.RedisCli.Close(ctx); err != nil {
+				log.Error(ctx, "failed to close redis client", err)
+				hasShutdownError = true
+			}
+		}
+
+		// flush telemetry last, so that it includes the requests served while shutting down
+		if svc.ShutdownTelemetry != nil {
+			if err := svc.ShutdownTelemetry(ctx); err != nil {
+				log.Error(ctx, "failed to shutdown telemetry", err)
+				hasShutdownError = true
+			}
+		}
 	}()
 
 	// wait for shutdown success (via cancel) or failure (timeout)
@@ -137,11 +226,31 @@ func (svc *Service) Close(ctx context.Context) error {
 	return nil
 }
 
+// drain waits for the graceful shutdown drain period, while the server keeps serving requests, unless ctx is
+// done first
+func (svc *Service) drain(ctx context.Context) {
+	period := svc.Config.GracefulShutdownDrain
+	if period <= 0 {
+		return
+	}
+
+	log.Info(ctx, "draining traffic before shutdown", log.Data{"graceful_shutdown_drain": period})
+	timer := time.NewTimer(period)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// registerCheckers adds the health checks of the dependencies to hc, tracking the upstreams in essential. Redis
+// isn't essential, as requests are still proxied without it.
 func registerCheckers(ctx context.Context, cfg *config.Config,
-	hc HealthChecker, redisCli clients.Redis) (err error) {
+	hc HealthChecker, redisCli clients.Redis, essential *essentialChecks) (err error) {
 	hasErrors := false
 
-	if cfg.EnableRedirects {
+	// the Redis client is only created when a feature uses it
+	if redisCli != nil {
 		err := hc.AddCheck("Redis", redisCli.Checker)
 		if err != nil {
 			hasErrors = true
@@ -149,6 +258,25 @@ func registerCheckers(ctx context.Context, cfg *config.Config,
 		}
 	}
 
+	if cfg.HealthCheckUpstreams {
+		legacy := clients.NewUpstream("legacy site", cfg.ProxiedServiceURL, cfg.HealthCheckLegacyPath,
+			cfg.HealthCheckLegacyStatus, cfg.HealthCheckLegacySeverity, cfg.HealthCheckUpstreamTimeout)
+		if err := hc.AddCheck("Legacy site", essential.track("Legacy site", legacy.Checker)); err != nil {
+			hasErrors = true
+			log.Error(ctx, "error adding check for legacy site", err)
+		}
+
+		// the releases fallback may also be enabled at runtime if the feature flags have a dynamic source
+		if cfg.EnableReleasesFallback || cfg.FeatureFlagsSource != "" {
+			wagtail := clients.NewUpstream("wagtail", cfg.WagtailURL, cfg.HealthCheckWagtailPath,
+				cfg.HealthCheckWagtailStatus, cfg.HealthCheckWagtailSeverity, cfg.HealthCheckUpstreamTimeout)
+			if err := hc.AddCheck("Wagtail", essential.track("Wagtail", wagtail.Checker)); err != nil {
+				hasErrors = true
+				log.Error(ctx, "error adding check for wagtail", err)
+			}
+		}
+	}
+
 	if hasErrors {
 		return errors.New("Error(s) registering checkers for healthcheck")
 	}
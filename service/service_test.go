@@ -3,7 +3,9 @@ package service_test
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
@@ -14,8 +16,11 @@ import (
 
 	"github.com/ONSdigital/dis-redirect-proxy/clients"
 	"github.com/ONSdigital/dis-redirect-proxy/config"
+	"github.com/ONSdigital/dis-redirect-proxy/flags"
+	"github.com/ONSdigital/dis-redirect-proxy/maintenance"
 	"github.com/ONSdigital/dis-redirect-proxy/service"
 	"github.com/ONSdigital/dis-redirect-proxy/service/mock"
+	disRedis "github.com/ONSdigital/dis-redis"
 
 	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
@@ -84,8 +89,9 @@ func TestRun(t *testing.T) {
 
 		redisClientMock := &clientsMock.RedisMock{
 			CheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error {
-				return nil
+				return state.Update(healthcheck.StatusOK, "redis is healthy", 0)
 			},
+			CloseFunc: func(ctx context.Context) error { return nil },
 		}
 		service.GetRedisClient = func(ctx context.Context, cfg *config.Config) (clients.Redis, error) {
 			return redisClientMock, nil
@@ -93,8 +99,8 @@ func TestRun(t *testing.T) {
 
 		Convey("Given that creating a redis client returns an error", func() {
 			initMock := &mock.InitialiserMock{
-				DoGetHTTPServerFunc:        funcDoGetHTTPServerNil,
-				DoGetHealthCheckFunc:       funcDoGetHealthcheckErr,
+				DoGetHTTPServerFunc:        funcDoGetHTTPServer,
+				DoGetHealthCheckFunc:       funcDoGetHealthcheckOk,
 				DoGetRequestMiddlewareFunc: funcDoGetRequestMiddleware,
 			}
 			svcErrors := make(chan error, 1)
@@ -102,14 +108,26 @@ func TestRun(t *testing.T) {
 			service.GetRedisClient = func(ctx context.Context, cfg *config.Config) (clients.Redis, error) {
 				return nil, errRedis
 			}
+			serverWg.Add(1)
 
-			Convey("Then service Run fails with the same error and no further initialisations are attempted", func() {
+			Convey("Then service Run starts without redis", func() {
 				_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
-				So(err, ShouldResemble, errRedis)
-
-				Convey("And no checkers are registered ", func() {
-					So(hcMock.AddCheckCalls(), ShouldHaveLength, 0)
+				So(err, ShouldBeNil)
+				serverWg.Wait()
+
+				_, err = svcList.RedisCli.GetValue(ctx, "/economy")
+				So(err, ShouldEqual, clients.ErrRedisNotConnected)
+
+				Convey("And the redis check reports that it isn't connected", func() {
+					So(hcMock.AddCheckCalls(), ShouldHaveLength, 1)
+					So(hcMock.AddCheckCalls()[0].Name, ShouldEqual, "Redis")
+					state := healthcheck.NewCheckState("Redis")
+					So(hcMock.AddCheckCalls()[0].Checker(ctx, state), ShouldBeNil)
+					So(state.Status(), ShouldEqual, healthcheck.StatusWarning)
+					So(state.Message(), ShouldEqual, "redis is not connected: failed to initialise redis")
 				})
+
+				So(svcList.RedisCli.Close(ctx), ShouldBeNil)
 			})
 		})
 
@@ -129,6 +147,10 @@ func TestRun(t *testing.T) {
 				So(svcList.HealthCheck, ShouldBeFalse)
 			})
 
+			Convey("And the redis client that was already created is closed", func() {
+				So(redisClientMock.CloseCalls(), ShouldHaveLength, 1)
+			})
+
 			Reset(func() {
 				// This reset is run after each `Convey` at the same scope (indentation)
 			})
@@ -148,6 +170,10 @@ func TestRun(t *testing.T) {
 				_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
 				So(hcMock.AddCheckCalls(), ShouldHaveLength, 1)
 				So(err.Error(), ShouldEqual, "unable to register checkers: Error(s) registering checkers for healthcheck")
+
+				Convey("And the redis client that was already created is closed", func() {
+					So(redisClientMock.CloseCalls(), ShouldHaveLength, 1)
+				})
 			})
 		})
 
@@ -166,7 +192,7 @@ func TestRun(t *testing.T) {
 			Convey("Then service Run succeeds and all the flags are set", func() {
 				So(err, ShouldBeNil)
 				So(svcList.HealthCheck, ShouldBeTrue)
-				So(svcList.RedisCli, ShouldResemble, redisClientMock)
+				So(svcList.RedisCli.(*clients.RedisConnection).Connected(), ShouldBeTrue)
 			})
 
 			Convey("The checkers are registered and the healthcheck and http server started", func() {
@@ -201,6 +227,11 @@ func TestRun(t *testing.T) {
 				sErr := <-svcErrors
 				So(sErr.Error(), ShouldResemble, fmt.Sprintf("failure in http listen and serve: %s", errServer.Error()))
 				So(len(failingServerMock.ListenAndServeCalls()), ShouldEqual, 1)
+
+				Convey("And it isn't reported as a failure to listen", func() {
+					var listenErr *service.ListenError
+					So(errors.As(sErr, &listenErr), ShouldBeFalse)
+				})
 			})
 
 			Reset(func() {
@@ -208,6 +239,61 @@ func TestRun(t *testing.T) {
 			})
 		})
 
+		Convey("Given that the http server can't listen on its bind address", func() {
+			listener, err := net.Listen("tcp", "localhost:0")
+			So(err, ShouldBeNil)
+			defer listener.Close()
+
+			initMock := &mock.InitialiserMock{
+				DoGetHealthCheckFunc: funcDoGetHealthcheckOk,
+				DoGetHTTPServerFunc: func(bindAddr string, router http.Handler) service.HTTPServer {
+					// the address is already in use by listener
+					return (&service.Init{}).DoGetHTTPServer(listener.Addr().String(), router)
+				},
+				DoGetRequestMiddlewareFunc: funcDoGetRequestMiddleware,
+			}
+			svcErrors := make(chan error, 1)
+			svcList := service.NewServiceList(initMock)
+			svc, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+			So(err, ShouldBeNil)
+
+			Convey("Then the failure to listen is returned in the error channel", func() {
+				sErr := <-svcErrors
+				var listenErr *service.ListenError
+				So(errors.As(sErr, &listenErr), ShouldBeTrue)
+				So(sErr.Error(), ShouldStartWith, "failure in http listen and serve: listen tcp")
+			})
+
+			So(svc.ServiceList.RedisCli.Close(ctx), ShouldBeNil)
+		})
+
+		Convey("When the upstream health checks are enabled", func() {
+			cfg.HealthCheckUpstreams = true
+			cfg.EnableReleasesFallback = true
+
+			initMock := &mock.InitialiserMock{
+				DoGetHTTPServerFunc:        funcDoGetHTTPServer,
+				DoGetHealthCheckFunc:       funcDoGetHealthcheckOk,
+				DoGetRequestMiddlewareFunc: funcDoGetRequestMiddleware,
+			}
+			svcErrors := make(chan error, 1)
+			serverWg.Add(1)
+			svcList := service.NewServiceList(initMock)
+
+			Convey("Then checkers for the legacy site and Wagtail are registered", func() {
+				_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
+				So(err, ShouldBeNil)
+				So(hcMock.AddCheckCalls(), ShouldHaveLength, 3)
+				So(hcMock.AddCheckCalls()[1].Name, ShouldEqual, "Legacy site")
+				So(hcMock.AddCheckCalls()[2].Name, ShouldEqual, "Wagtail")
+			})
+
+			Reset(func() {
+				cfg.HealthCheckUpstreams = false
+				cfg.EnableReleasesFallback = false
+			})
+		})
+
 		Convey("When EnableRedirects is set to false", func() {
 			cfg.EnableRedirects = false
 
@@ -224,7 +310,8 @@ func TestRun(t *testing.T) {
 				_, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
 				So(err, ShouldBeNil)
 
-				Convey("And no checkers are registered ", func() {
+				Convey("And no redis client is created, and no checkers are registered", func() {
+					So(svcList.RedisCli, ShouldBeNil)
 					So(hcMock.AddCheckCalls(), ShouldHaveLength, 0)
 				})
 			})
@@ -232,31 +319,307 @@ func TestRun(t *testing.T) {
 	})
 }
 
+func TestReload(t *testing.T) {
+	Convey("Given a running service", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		// copy the shared config, so that enabling redirects here doesn't enable them for the other tests
+		cfgCopy := *cfg
+		cfg = &cfgCopy
+		cfg.GracefulShutdownDrain = 0
+		cfg.EnableRedirects = true
+
+		hcMock := &mock.HealthCheckerMock{
+			AddCheckFunc: func(name string, checker healthcheck.Checker) error { return nil },
+			StartFunc:    func(ctx context.Context) {},
+			StopFunc:     func() {},
+		}
+		var handler http.Handler
+		initMock := &mock.InitialiserMock{
+			DoGetHTTPServerFunc: func(bindAddr string, router http.Handler) service.HTTPServer {
+				handler = router
+				return &mock.HTTPServerMock{ListenAndServeFunc: func() error { return nil }}
+			},
+			DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.HealthChecker, error) {
+				return hcMock, nil
+			},
+			DoGetRequestMiddlewareFunc: func() service.RequestMiddleware { return &service.NoOpRequestMiddleware{} },
+		}
+		// polls counts the reads of each key made with a context that hasn't been cancelled
+		var pollsMu sync.Mutex
+		polls := map[string]int{}
+		livePolls := func(key string) int {
+			pollsMu.Lock()
+			defer pollsMu.Unlock()
+			return polls[key]
+		}
+		service.GetRedisClient = func(ctx context.Context, cfg *config.Config) (clients.Redis, error) {
+			return &clientsMock.RedisMock{
+				CheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error {
+					return state.Update(healthcheck.StatusOK, "redis is healthy", 0)
+				},
+				CloseFunc: func(ctx context.Context) error { return nil },
+				GetValueFunc: func(ctx context.Context, key string) (string, error) {
+					if err := ctx.Err(); err != nil {
+						return "", err
+					}
+					pollsMu.Lock()
+					defer pollsMu.Unlock()
+					polls[key]++
+					return "", disRedis.ErrKeyNotFound
+				},
+			}, nil
+		}
+
+		svcErrors := make(chan error, 1)
+		svc, err := service.Run(ctx, cfg, service.NewServiceList(initMock), testBuildTime, testGitCommit, testVersion, svcErrors)
+		So(err, ShouldBeNil)
+		router := svc.Router
+		Reset(func() {
+			// stop the polling of whichever routes are in use when each case ends
+			if svc.Maintenance != nil {
+				svc.Maintenance.Stop()
+			}
+			svc.Proxy.Flags.Stop()
+		})
+
+		Convey("When the config is changed and reloaded", func() {
+			t.Setenv("ADMIN_API_KEY", "reloaded-key")
+			err := svc.Reload(ctx)
+
+			Convey("Then the new config and router replace the old ones", func() {
+				So(err, ShouldBeNil)
+				So(svc.Config.AdminAPIKey, ShouldEqual, "reloaded-key")
+				So(svc.Router != router, ShouldBeTrue)
+			})
+
+			Convey("And requests to the server are handled by the new router", func() {
+				req := httptest.NewRequest(http.MethodPost, "/admin/reload", http.NoBody)
+				req.Header.Set("Authorization", "Bearer reloaded-key")
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				So(w.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When the config is reloaded with a context that is cancelled once the reload returns", func() {
+			t.Setenv("MAINTENANCE_ENABLED", "true")
+			t.Setenv("MAINTENANCE_POLL_INTERVAL", "5ms")
+			t.Setenv("FEATURE_FLAGS_SOURCE", config.FeatureFlagsSourceRedis)
+			t.Setenv("FEATURE_FLAGS_POLL_INTERVAL", "5ms")
+			reloadCtx, cancel := context.WithCancel(ctx)
+			So(svc.Reload(reloadCtx), ShouldBeNil)
+			cancel()
+			maintenancePolls, flagPolls := livePolls(maintenance.RedisKey), livePolls(flags.RedisKey)
+
+			Convey("Then the maintenance windows and feature flags are still polled", func() {
+				deadline := time.Now().Add(time.Second)
+				for time.Now().Before(deadline) &&
+					(livePolls(maintenance.RedisKey) <= maintenancePolls || livePolls(flags.RedisKey) <= flagPolls) {
+					time.Sleep(time.Millisecond)
+				}
+				So(livePolls(maintenance.RedisKey), ShouldBeGreaterThan, maintenancePolls)
+				So(livePolls(flags.RedisKey), ShouldBeGreaterThan, flagPolls)
+			})
+		})
+
+		Convey("When the changed config is invalid", func() {
+			t.Setenv("ADMIN_API_KEY", "reloaded-key")
+			t.Setenv("CACHE_ENABLED", "not-a-bool")
+			err := svc.Reload(ctx)
+
+			Convey("Then the old config and router are kept", func() {
+				So(err, ShouldNotBeNil)
+				So(svc.Config, ShouldEqual, cfg)
+				So(svc.Router == router, ShouldBeTrue)
+			})
+		})
+
+		Convey("When the service is shutting down", func() {
+			svc.Server = &mock.HTTPServerMock{ShutdownFunc: func(ctx context.Context) error { return nil }}
+			So(svc.Close(ctx), ShouldBeNil)
+
+			Convey("Then the config can't be reloaded", func() {
+				So(svc.Reload(ctx), ShouldNotBeNil)
+				So(svc.Router == router, ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestProbes(t *testing.T) {
+	Convey("Given a running service", t, func() {
+		cfg, err := config.Get()
+		So(err, ShouldBeNil)
+		legacyStatus := http.StatusOK
+		legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(legacyStatus)
+		}))
+		Reset(legacy.Close)
+
+		// copy the shared config, so that the upstream checks aren't enabled for the other tests
+		cfgCopy := *cfg
+		cfg = &cfgCopy
+		cfg.GracefulShutdownDrain = 0
+		cfg.EnableRedirects = true
+		cfg.HealthCheckUpstreams = true
+		cfg.ProxiedServiceURL = legacy.URL
+
+		checkers := map[string]healthcheck.Checker{}
+		check := func(name string) string {
+			state := healthcheck.NewCheckState(name)
+			So(checkers[name](ctx, state), ShouldBeNil)
+			return state.Status()
+		}
+		hcMock := &mock.HealthCheckerMock{
+			AddCheckFunc: func(name string, checker healthcheck.Checker) error {
+				checkers[name] = checker
+				return nil
+			},
+			StartFunc: func(ctx context.Context) {},
+			StopFunc:  func() {},
+		}
+		var handler http.Handler
+		initMock := &mock.InitialiserMock{
+			DoGetHTTPServerFunc: func(bindAddr string, router http.Handler) service.HTTPServer {
+				handler = router
+				return &mock.HTTPServerMock{
+					ListenAndServeFunc: func() error { return nil },
+					ShutdownFunc:       func(ctx context.Context) error { return nil },
+				}
+			},
+			DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.HealthChecker, error) {
+				return hcMock, nil
+			},
+			DoGetRequestMiddlewareFunc: func() service.RequestMiddleware { return &service.NoOpRequestMiddleware{} },
+		}
+		redisStatus := healthcheck.StatusOK
+		redisMock := &clientsMock.RedisMock{
+			CheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error {
+				return state.Update(redisStatus, "redis check", 0)
+			},
+			CloseFunc: func(ctx context.Context) error { return nil },
+			GetValueFunc: func(ctx context.Context, key string) (string, error) {
+				return "", disRedis.ErrKeyNotFound
+			},
+		}
+		service.GetRedisClient = func(ctx context.Context, cfg *config.Config) (clients.Redis, error) {
+			return redisMock, nil
+		}
+
+		svcErrors := make(chan error, 1)
+		svc, err := service.Run(ctx, cfg, service.NewServiceList(initMock), testBuildTime, testGitCommit, testVersion, svcErrors)
+		So(err, ShouldBeNil)
+
+		probe := func(path string) int {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+			return w.Code
+		}
+
+		Convey("Then it is alive and ready", func() {
+			So(probe(service.LivenessPath), ShouldEqual, http.StatusOK)
+			So(probe(service.ReadinessPath), ShouldEqual, http.StatusOK)
+
+			Convey("And the probes don't look up redirects in Redis", func() {
+				So(redisMock.GetValueCalls(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the legacy site is found to be down", func() {
+			legacyStatus = http.StatusInternalServerError
+			So(check("Legacy site"), ShouldEqual, healthcheck.StatusCritical)
+
+			Convey("Then it is alive but not ready, without waiting for the health check to be requested", func() {
+				So(probe(service.LivenessPath), ShouldEqual, http.StatusOK)
+				So(probe(service.ReadinessPath), ShouldEqual, http.StatusServiceUnavailable)
+			})
+
+			Convey("And when it is found to have recovered", func() {
+				legacyStatus = http.StatusOK
+				So(check("Legacy site"), ShouldEqual, healthcheck.StatusOK)
+
+				Convey("Then it is ready again", func() {
+					So(probe(service.ReadinessPath), ShouldEqual, http.StatusOK)
+				})
+			})
+		})
+
+		Convey("When Redis is found to be critical", func() {
+			redisConn := svc.ServiceList.RedisCli.(*clients.RedisConnection)
+			for deadline := time.Now().Add(time.Second); !redisConn.Connected() && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+			redisStatus = healthcheck.StatusCritical
+			So(check("Redis"), ShouldEqual, healthcheck.StatusCritical)
+
+			Convey("Then it is still ready, as requests are proxied without Redis", func() {
+				So(probe(service.ReadinessPath), ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When the service is shutting down", func() {
+			So(svc.Close(ctx), ShouldBeNil)
+
+			Convey("Then it is alive but not ready", func() {
+				So(probe(service.LivenessPath), ShouldEqual, http.StatusOK)
+				So(probe(service.ReadinessPath), ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+	})
+
+	Convey("Given a service that hasn't started", t, func() {
+		svc := &service.Service{HealthCheck: &mock.HealthCheckerMock{}}
+
+		Convey("Then it isn't ready", func() {
+			w := httptest.NewRecorder()
+			svc.Readyz(w, httptest.NewRequest(http.MethodGet, service.ReadinessPath, http.NoBody))
+			So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+		})
+	})
+}
+
 func TestClose(t *testing.T) {
 	Convey("Having a correctly initialised service", t, func() {
 		cfg, cfgErr := config.Get()
 		So(cfgErr, ShouldBeNil)
+		cfg.GracefulShutdownDrain = 0
+		cfg.EnableRedirects = true
 
-		hcStopped := false
+		var closed []string
 
 		// healthcheck Stop does not depend on any other service being closed/stopped
 		hcMock := &mock.HealthCheckerMock{
 			AddCheckFunc: func(name string, checker healthcheck.Checker) error { return nil },
 			StartFunc:    func(ctx context.Context) {},
-			StopFunc:     func() { hcStopped = true },
+			StopFunc:     func() { closed = append(closed, "healthcheck") },
 		}
 
 		// server Shutdown will fail if healthcheck is not stopped
 		serverMock := &mock.HTTPServerMock{
 			ListenAndServeFunc: func() error { return nil },
 			ShutdownFunc: func(ctx context.Context) error {
-				if !hcStopped {
+				if len(closed) == 0 || closed[0] != "healthcheck" {
 					return errors.New("Server stopped before healthcheck")
 				}
+				closed = append(closed, "server")
 				return nil
 			},
 		}
 
+		redisMock := &clientsMock.RedisMock{
+			CheckerFunc: func(ctx context.Context, state *healthcheck.CheckState) error {
+				return state.Update(healthcheck.StatusOK, "redis is healthy", 0)
+			},
+			CloseFunc: func(ctx context.Context) error {
+				closed = append(closed, "redis")
+				return nil
+			},
+		}
+		service.GetRedisClient = func(ctx context.Context, cfg *config.Config) (clients.Redis, error) {
+			return redisMock, nil
+		}
+
 		Convey("Closing the service results in all the dependencies being closed in the expected order", func() {
 			initMock := &mock.InitialiserMock{
 				DoGetHTTPServerFunc: func(bindAddr string, router http.Handler) service.HTTPServer { return serverMock },
@@ -270,11 +633,16 @@ func TestClose(t *testing.T) {
 			svcList := service.NewServiceList(initMock)
 			svc, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
 			So(err, ShouldBeNil)
+			svc.ShutdownTelemetry = func(ctx context.Context) error {
+				closed = append(closed, "telemetry")
+				return nil
+			}
 
 			err = svc.Close(context.Background())
 			So(err, ShouldBeNil)
 			So(len(hcMock.StopCalls()), ShouldEqual, 1)
 			So(len(serverMock.ShutdownCalls()), ShouldEqual, 1)
+			So(closed, ShouldResemble, []string{"healthcheck", "server", "redis", "telemetry"})
 		})
 
 		Convey("If services fail to stop, the Close operation tries to close all dependencies and returns an error", func() {
@@ -297,12 +665,56 @@ func TestClose(t *testing.T) {
 			svcList := service.NewServiceList(initMock)
 			svc, err := service.Run(ctx, cfg, svcList, testBuildTime, testGitCommit, testVersion, svcErrors)
 			So(err, ShouldBeNil)
+			svc.ShutdownTelemetry = func(ctx context.Context) error {
+				return errors.New("Failed to flush telemetry")
+			}
 
 			err = svc.Close(context.Background())
 			So(err, ShouldNotBeNil)
 			So(err.Error(), ShouldEqual, "failed to shutdown gracefully")
 			So(len(hcMock.StopCalls()), ShouldEqual, 1)
 			So(len(failingserverMock.ShutdownCalls()), ShouldEqual, 1)
+			So(len(redisMock.CloseCalls()), ShouldEqual, 1)
+		})
+
+		Convey("If a drain period is configured, the server keeps serving until it has passed", func() {
+			cfg.GracefulShutdownDrain = 50 * time.Millisecond
+			initMock := &mock.InitialiserMock{
+				DoGetHTTPServerFunc: func(bindAddr string, router http.Handler) service.HTTPServer { return serverMock },
+				DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.HealthChecker, error) {
+					return hcMock, nil
+				},
+				DoGetRequestMiddlewareFunc: func() service.RequestMiddleware { return &service.NoOpRequestMiddleware{} },
+			}
+
+			svcErrors := make(chan error, 1)
+			svc, err := service.Run(ctx, cfg, service.NewServiceList(initMock), testBuildTime, testGitCommit, testVersion, svcErrors)
+			So(err, ShouldBeNil)
+
+			start := time.Now()
+			So(svc.Close(context.Background()), ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, cfg.GracefulShutdownDrain)
+			So(closed, ShouldResemble, []string{"healthcheck", "server", "redis"})
+		})
+
+		Convey("If the service is closed after a server error, it shuts down without waiting for the drain period", func() {
+			cfg.GracefulShutdownDrain = time.Minute
+			initMock := &mock.InitialiserMock{
+				DoGetHTTPServerFunc: func(bindAddr string, router http.Handler) service.HTTPServer { return serverMock },
+				DoGetHealthCheckFunc: func(cfg *config.Config, buildTime string, gitCommit string, version string) (service.HealthChecker, error) {
+					return hcMock, nil
+				},
+				DoGetRequestMiddlewareFunc: func() service.RequestMiddleware { return &service.NoOpRequestMiddleware{} },
+			}
+
+			svcErrors := make(chan error, 1)
+			svc, err := service.Run(ctx, cfg, service.NewServiceList(initMock), testBuildTime, testGitCommit, testVersion, svcErrors)
+			So(err, ShouldBeNil)
+
+			start := time.Now()
+			So(svc.CloseAfterError(context.Background()), ShouldBeNil)
+			So(time.Since(start), ShouldBeLessThan, cfg.GracefulShutdownDrain)
+			So(closed, ShouldResemble, []string{"healthcheck", "server", "redis"})
 		})
 
 		Convey("If service times out while shutting down, the Close operation fails with the expected error", func() {
@@ -310,7 +722,9 @@ func TestClose(t *testing.T) {
 			timeoutServerMock := &mock.HTTPServerMock{
 				ListenAndServeFunc: func() error { return nil },
 				ShutdownFunc: func(ctx context.Context) error {
-					time.Sleep(2 * time.Millisecond)
+					// shutdown only finishes once the timeout has expired
+					<-ctx.Done()
+					time.Sleep(time.Millisecond)
 					return nil
 				},
 			}